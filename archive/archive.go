@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Separator joins the path of an archive and the path of an entry inside it,
+// as in "bundle.zip!/src/main.go".
+const Separator = "!/"
+
+// ErrLimitExceeded is returned when an archive expands to more data than
+// allowed by Options.MaxSize.
+var ErrLimitExceeded = errors.New("archive size limit exceeded")
+
+// Options limits how archives are walked.
+type Options struct {
+	MaxDepth int   // levels of nested archives to open; 0 walks only the outer archive
+	MaxSize  int64 // total uncompressed bytes read from an archive and its nested archives
+}
+
+// Entry is a regular file inside an archive.
+type Entry struct {
+	Path string // archive path and entry name joined by Separator
+	Data []byte // uncompressed content
+}
+
+// IsArchive reports whether name has the extension of a supported archive
+// format: .zip, .tar, .tar.gz or .tgz.
+func IsArchive(name string) bool {
+	return format(name) != ""
+}
+
+// format returns the archive format of name based on its extension.
+func format(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tgz"
+	}
+	return ""
+}
+
+// walker carries the state shared by an archive and its nested archives.
+type walker struct {
+	opts      Options
+	remaining int64
+	fn        func(e Entry) error
+}
+
+// Walk calls fn for every regular file in the archive at name, in archive
+// order. Entries that are archives themselves are walked in turn while the
+// nesting depth allows it and are otherwise passed to fn like other files.
+func Walk(name string, opts Options, fn func(e Entry) error) error {
+	w := &walker{opts: opts, remaining: opts.MaxSize, fn: fn}
+
+	if format(name) == "zip" {
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return w.walkZip(name, &zr.Reader, 0)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return w.walkTar(name, f, format(name) == "tgz", 0)
+}
+
+// WalkBytes is Walk for the archive named name whose content is data, such
+// as an archive read from a git repository.
+func WalkBytes(name string, data []byte, opts Options, fn func(e Entry) error) error {
+	w := &walker{opts: opts, remaining: opts.MaxSize, fn: fn}
+
+	if format(name) == "zip" {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return w.walkZip(name, zr, 0)
+	}
+	return w.walkTar(name, bytes.NewReader(data), format(name) == "tgz", 0)
+}
+
+// walkZip walks the entries of a zip archive.
+func (w *walker) walkZip(name string, zr *zip.Reader, depth int) error {
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || !zf.Mode().IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name+Separator+zf.Name, err)
+		}
+		err = w.entry(name, zf.Name, rc, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar walks the entries of a tar archive, gunzipping it first if needed.
+func (w *walker) walkTar(name string, r io.Reader, gzipped bool, depth int) error {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if err := w.entry(name, hdr.Name, tr, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// entry reads one archive entry within the size limit and either walks it as
+// a nested archive or passes it to fn.
+func (w *walker) entry(archiveName, entryName string, r io.Reader, depth int) error {
+	entryPath := archiveName + Separator + strings.TrimPrefix(path.Clean("/"+entryName), "/")
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, w.remaining+1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", entryPath, err)
+	}
+	if int64(len(data)) > w.remaining {
+		return fmt.Errorf("%s: %w (%d bytes)", entryPath, ErrLimitExceeded, w.opts.MaxSize)
+	}
+	w.remaining -= int64(len(data))
+
+	if depth < w.opts.MaxDepth {
+		switch format(entryName) {
+		case "zip":
+			zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return fmt.Errorf("%s: %w", entryPath, err)
+			}
+			return w.walkZip(entryPath, zr, depth+1)
+		case "tar", "tgz":
+			return w.walkTar(entryPath, bytes.NewReader(data), format(entryName) == "tgz", depth+1)
+		}
+	}
+
+	return w.fn(Entry{Path: entryPath, Data: data})
+}
@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipData returns a zip archive holding the given name/content pairs.
+func zipData(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		w, err := zw.Create(entries[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entries[i+1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tgzData returns a gzipped tar archive holding the given name/content pairs.
+func tgzData(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for i := 0; i < len(entries); i += 2 {
+		hdr := &tar.Header{Name: entries[i], Mode: 0644, Size: int64(len(entries[i+1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(entries[i+1]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	inner := tgzData(t, "docs/readme.md", "inner readme")
+	path := filepath.Join(dir, "bundle.zip")
+	if err := os.WriteFile(path, zipData(t, "src/main.go", "package main", "vendor.tgz", string(inner)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected map[string]string
+		err      error
+	}{
+		{
+			name: "nested archives",
+			opts: Options{MaxDepth: 1, MaxSize: 1 << 20},
+			expected: map[string]string{
+				path + "!/src/main.go":                "package main",
+				path + "!/vendor.tgz!/docs/readme.md": "inner readme",
+			},
+		},
+		{
+			name: "depth limit",
+			opts: Options{MaxDepth: 0, MaxSize: 1 << 20},
+			expected: map[string]string{
+				path + "!/src/main.go": "package main",
+				path + "!/vendor.tgz":  string(inner),
+			},
+		},
+		{
+			name: "size limit",
+			opts: Options{MaxDepth: 1, MaxSize: 16},
+			err:  ErrLimitExceeded,
+		},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walks := map[string]func(Options, func(Entry) error) error{
+		"file": func(opts Options, fn func(Entry) error) error {
+			return Walk(path, opts, fn)
+		},
+		"bytes": func(opts Options, fn func(Entry) error) error {
+			return WalkBytes(path, data, opts, fn)
+		},
+	}
+
+	for _, test := range tests {
+		for walkName, walk := range walks {
+			test, walk := test, walk
+			t.Run(test.name+" from "+walkName, func(t *testing.T) {
+				entries := map[string]string{}
+				err := walk(test.opts, func(e Entry) error {
+					entries[e.Path] = string(e.Data)
+					return nil
+				})
+				if test.err != nil {
+					if !errors.Is(err, test.err) {
+						t.Fatalf("Unexpected error, expected %v, got %v", test.err, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if len(entries) != len(test.expected) {
+					t.Fatalf("Unexpected entries %v", entries)
+				}
+				for name, content := range test.expected {
+					if entries[name] != content {
+						t.Errorf("Unexpected content for %s, expected %q, got %q", name, content, entries[name])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"bundle.zip", true},
+		{"src.TAR", true},
+		{"release.tar.gz", true},
+		{"release.tgz", true},
+		{"notes.gz", false},
+		{"main.go", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := IsArchive(test.name); result != test.expected {
+				t.Errorf("Unexpected value, expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
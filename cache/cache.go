@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	indexFile  = "index.json"
+	objectsDir = "objects"
+)
+
+// Entry describes a file as it was when its text was cached.
+type Entry struct {
+	Size    int64           `json:"size"`
+	ModTime time.Time       `json:"mtime"` // zero for files without one, such as archive entries
+	Sum     string          `json:"sum"`   // hex SHA-256 of the content
+	Data    json.RawMessage `json:"data"`  // what the caller keeps about the file besides its text
+}
+
+// index is the content of the index file.
+type index struct {
+	Key   string                     `json:"key"`
+	Files map[string]*Entry          `json:"files"`
+	State map[string]json.RawMessage `json:"state,omitempty"`
+}
+
+// Cache keeps the text of files between runs in a directory, with an index
+// of the files it was extracted from. It is safe for concurrent use.
+type Cache struct {
+	dir string
+
+	mu    sync.Mutex
+	index index
+	seen  map[string]bool // files looked up or stored in this run
+}
+
+// Open opens the cache in dir, creating the directory if needed. key
+// identifies the settings the text was produced with: when it differs from
+// the key the cache was written with, the cached text is discarded.
+func Open(dir, key string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, objectsDir), 0755); err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: dir, seen: map[string]bool{}}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		// A corrupt index is discarded like a stale one.
+		_ = json.Unmarshal(data, &c.index)
+	}
+	if c.index.Key != key {
+		if err := os.RemoveAll(filepath.Join(dir, objectsDir)); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Join(dir, objectsDir), 0755); err != nil {
+			return nil, err
+		}
+		c.index = index{Key: key}
+	}
+	if c.index.Files == nil {
+		c.index.Files = map[string]*Entry{}
+	}
+	if c.index.State == nil {
+		c.index.State = map[string]json.RawMessage{}
+	}
+	return c, nil
+}
+
+// Get returns the entry of the file at path if its text is cached for its
+// current content, or nil. The content is taken as unchanged when the size
+// and modification time match; otherwise, or when modTime is zero, it is
+// read through open and its hash compared. The hash is returned when it was
+// computed, for Put.
+func (c *Cache) Get(path string, size int64, modTime time.Time, open func() (io.ReadCloser, error)) (*Entry, string, error) {
+	c.mu.Lock()
+	entry := c.index.Files[path]
+	c.seen[path] = true
+	c.mu.Unlock()
+
+	if entry != nil && entry.Size == size && !modTime.IsZero() && entry.ModTime.Equal(modTime) {
+		return entry, "", nil
+	}
+
+	rc, err := open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return nil, "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if entry == nil || entry.Size != size || entry.Sum != sum {
+		return nil, sum, nil
+	}
+
+	c.mu.Lock()
+	entry.ModTime = modTime
+	c.mu.Unlock()
+	return entry, sum, nil
+}
+
+// Put records entry for the file at path, whose text has been written to
+// the object created by Create, if any.
+func (c *Cache) Put(path string, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index.Files[path] = entry
+	c.seen[path] = true
+}
+
+// Create creates or truncates the object holding the text of the file at
+// path.
+func (c *Cache) Create(path string) (*os.File, error) {
+	return os.Create(c.Object(path))
+}
+
+// Open opens the object holding the text of the file at path.
+func (c *Cache) Open(path string) (*os.File, error) {
+	return os.Open(c.Object(path))
+}
+
+// Object returns the path of the object holding the text of the file at
+// path.
+func (c *Cache) Object(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(c.dir, objectsDir, hex.EncodeToString(sum[:]))
+}
+
+// State returns the value stored with SetState under name, or nil.
+func (c *Cache) State(name string) json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index.State[name]
+}
+
+// SetState stores a value under name, such as state shared by the cached
+// texts, to be returned by State in later runs.
+func (c *Cache) SetState(name string, value json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index.State[name] = value
+}
+
+// Close forgets the files that were neither looked up nor stored since Open
+// and for which gone returns true, removing their text, and writes the index.
+func (c *Cache) Close(gone func(path string) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path := range c.index.Files {
+		if !c.seen[path] && gone(path) {
+			delete(c.index.Files, path)
+			if err := os.Remove(c.Object(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return c.flush()
+}
+
+// Flush writes the index, keeping the cache open.
+func (c *Cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flush()
+}
+
+func (c *Cache) flush() error {
+	data, err := json.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.dir, indexFile+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, indexFile))
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opened := 0
+	content := "Hello."
+	open := func() (io.ReadCloser, error) {
+		opened++
+		return ioutil.NopCloser(strings.NewReader(content)), nil
+	}
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	c, err := Open(dir, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, sum, err := c.Get("a.txt", 6, mtime, open)
+	if err != nil || entry != nil || sum == "" {
+		t.Fatalf("Get on an empty cache = %v, %q, %v", entry, sum, err)
+	}
+	object, err := c.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.WriteString("hello")
+	object.Close()
+	c.Put("a.txt", &Entry{Size: 6, ModTime: mtime, Sum: sum, Data: json.RawMessage(`{}`)})
+	c.SetState("state", json.RawMessage(`1`))
+	if err := c.Close(func(string) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		size       int64
+		mtime      time.Time
+		content    string
+		expectHit  bool
+		expectRead bool
+	}{
+		{name: "unchanged", key: "key", size: 6, mtime: mtime, content: "Hello.", expectHit: true},
+		{name: "touched", key: "key", size: 6, mtime: mtime.Add(time.Hour), content: "Hello.", expectHit: true, expectRead: true},
+		{name: "modified", key: "key", size: 6, mtime: mtime.Add(time.Hour), content: "Hullo.", expectRead: true},
+		{name: "no modification time", key: "key", size: 6, content: "Hello.", expectHit: true, expectRead: true},
+		{name: "other settings", key: "other", size: 6, mtime: mtime, content: "Hello.", expectRead: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := Open(dir, test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opened, content = 0, test.content
+			entry, _, err := c.Get("a.txt", test.size, test.mtime, open)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hit := entry != nil; hit != test.expectHit {
+				t.Errorf("Expected hit %v, got %v", test.expectHit, hit)
+			}
+			if read := opened > 0; read != test.expectRead {
+				t.Errorf("Expected content read %v, got %v", test.expectRead, read)
+			}
+			if hit := string(c.State("state")) == "1"; hit != (test.key == "key") {
+				t.Errorf("Unexpected state %q", c.State("state"))
+			}
+		})
+	}
+
+	// Opening the cache with other settings discarded the cached text.
+	objects, err := ioutil.ReadDir(filepath.Join(dir, objectsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("Expected the objects to be removed with the settings change, got %d", len(objects))
+	}
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"textractor/config"
 	"textractor/processor"
@@ -15,8 +18,46 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := processor.ProcessDirectory(cfg); err != nil {
+	if cfg.Watch {
+		watch(cfg)
+		return
+	}
+	stats, err := processor.Process(cfg)
+	if err != nil {
 		fmt.Printf("Error processing directory: %v\n", err)
 		os.Exit(1)
 	}
+	for _, err := range stats.Errors {
+		fmt.Printf("Skipped: %v\n", err)
+	}
+	if cfg.CacheDir != "" {
+		fmt.Printf("Cache: %d hits, %d misses\n", stats.CacheHits, stats.CacheMisses)
+	}
+	if stats.Truncated > 0 {
+		fmt.Printf("Truncated: %d files\n", stats.Truncated)
+	}
+}
+
+// watch keeps the output up to date until the process is interrupted.
+func watch(cfg *config.Config) {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	err := processor.Watch(cfg, stop, func(u processor.Update) {
+		for _, err := range u.Errors {
+			fmt.Printf("Error: %v\n", err)
+		}
+		if len(u.Outputs) > 0 {
+			fmt.Printf("Updated %s (%d files changed)\n", strings.Join(u.Outputs, ", "), len(u.Files))
+		}
+	})
+	if err != nil {
+		fmt.Printf("Error watching directory: %v\n", err)
+		os.Exit(1)
+	}
 }
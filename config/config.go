@@ -5,23 +5,62 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"regexp"
+	"time"
 
 	"github.com/spf13/pflag"
+
+	"textractor/redact"
 )
 
 // Config represents the configuration options for the program
 type Config struct {
-	InputDir        string   // the input directory to search for files
-	OutputFile      string   // the name of the output file
-	IgnoredExts     []string // a list of file extensions to ignore
-	IncludedExts    []string // a list of file extensions to only include
-	MaxWordsPerFile int      // the maximum number of words per output file
-	IncludedDirs    []string // New
-	ExcludedDirs    []string // New
+	InputDir        string            // the input directory to search for files
+	OutputFile      string            // the name of the output file
+	IgnoredExts     []string          // a list of file extensions to ignore
+	IncludedExts    []string          // a list of file extensions to only include
+	MaxWordsPerFile int               // the maximum number of words per output file
+	IncludedDirs    []string          // New
+	ExcludedDirs    []string          // New
+	HTMLKeepLinks   bool              // keep link targets when converting HTML to text
+	HTMLRaw         bool              // keep HTML source instead of converting it to text
+	NotebookOutputs bool              // include the text outputs of notebook code cells
+	StructuredMode  string            // "flatten" or "pretty" for JSON, YAML and TOML files
+	StructuredText  bool              // keep only the string values of structured files
+	MaxArrayItems   int               // array elements kept when flattening structured files
+	CSVRecords      bool              // render CSV and TSV rows as "column: value" records
+	SubtitleMarks   time.Duration     // interval of timestamp markers in subtitle text, 0 for none
+	ProseExts       []string          // extensions of Markdown and reStructuredText files converted to plain prose
+	CommentsOnly    bool              // extract only the comments and docstrings of source files
+	StripComments   bool              // remove comments and blank lines from source files
+	RedactSecrets   bool              // replace credentials with placeholders in the output
+	FailOnSecrets   bool              // stop with an error when a file contains credentials
+	PII             []string          // kinds of personal data to redact, "all" for every kind
+	PIIPseudonyms   bool              // replace personal data with consistent numbered tokens
+	PIIReport       string            // file receiving the personal data counts of each file
+	SkipIfContains  []string          // regular expressions excluding the files whose text matches
+	Dedup           string            // "reference" or "skip" duplicate files, "" to keep them
+	DedupNear       float64           // similarity above which files are near duplicates, 0 for identical files only
+	MaxFileSize     int64             // size above which files are skipped, 0 for no limit
+	TruncateAt      Limit             // amount of text kept from each file, 0 for no limit
+	TruncateTail    bool              // keep the end of truncated files as well as their start
+	CacheDir        string            // directory keeping the text of files between runs, "" for none
+	Since           string            // git revision; only files added or modified since it are extracted
+	Diff            string            // with Since, "only" to write a diff of each file instead of its text, "both" for both
+	GitRev          string            // git revision whose files are extracted instead of those of the input directory
+	Watch           bool              // keep the output up to date as the input files change
+	ConfigFile      string            // path of the JSON configuration file
+	Commands        []ExternalCommand // external programs extracting the text of some files, from the configuration file
+	Transforms      []Transform       // replacements applied to the text of files, from the configuration file
+	Archives        bool              // descend into zip and tar archives
+	ArchiveDepth    int               // levels of nested archives to descend into
+	ArchiveMaxSize  int64             // maximum uncompressed bytes read from one archive
 }
 
 const (
 	MAX_WORDS_PER_FILE = math.MaxInt64
+	ARCHIVE_DEPTH      = 3
+	ARCHIVE_MAX_SIZE   = 1 << 30
 )
 
 // ParseCommandLineArguments parses the command-line arguments and returns the configuration options
@@ -36,6 +75,38 @@ func ParseCommandLineArguments(args []string) (*Config, error) {
 	flags.StringSliceVarP(&cfg.IgnoredExts, "ignored-exts", "i", []string{".jpg", ".png"}, "comma-separated list of ignored file extensions")
 	flags.StringSliceVar(&cfg.IncludedExts, "only", []string{}, "comma-separated list of file extensions to process exclusively")
 	flags.IntVarP(&cfg.MaxWordsPerFile, "max-words-per-file", "w", MAX_WORDS_PER_FILE, "maximum number of words per output file")
+	flags.BoolVar(&cfg.HTMLKeepLinks, "html-links", false, "keep link targets when converting HTML to text")
+	flags.BoolVar(&cfg.HTMLRaw, "raw-html", false, "keep HTML source instead of converting it to text")
+	flags.BoolVar(&cfg.NotebookOutputs, "notebook-outputs", false, "include the text outputs of Jupyter notebook code cells")
+	flags.StringVar(&cfg.StructuredMode, "structured", "", "convert JSON, YAML and TOML files: flatten or pretty")
+	flags.BoolVar(&cfg.StructuredText, "structured-strings-only", false, "keep only the string values of flattened JSON, YAML and TOML files")
+	flags.IntVar(&cfg.MaxArrayItems, "max-array-items", 0, "array elements kept when flattening structured files, 0 for all")
+	flags.BoolVar(&cfg.CSVRecords, "csv-records", false, "render CSV and TSV rows as \"column: value\" records")
+	flags.DurationVar(&cfg.SubtitleMarks, "subtitle-timestamps", 0, "keep a timestamp in subtitle text at most this often (e.g. 1m), 0 for none")
+	flags.StringSliceVar(&cfg.ProseExts, "prose", []string{}, "comma-separated list of Markdown and reStructuredText extensions to convert to plain prose (e.g. .md,.mdx,.rst)")
+	flags.BoolVar(&cfg.CommentsOnly, "comments-only", false, "extract only the comments and docstrings of source files, with the symbol they document")
+	flags.BoolVar(&cfg.StripComments, "strip-comments", false, "remove comments, blank lines and trailing whitespace from source files")
+	flags.BoolVar(&cfg.RedactSecrets, "redact-secrets", false, "replace keys, tokens, private keys and passwords with placeholders")
+	flags.BoolVar(&cfg.FailOnSecrets, "fail-on-secrets", false, "stop with an error reporting the file and line of any secret found")
+	flags.StringSliceVar(&cfg.PII, "pii", []string{}, "comma-separated list of personal data to redact: email, phone, ip, card, iban or all")
+	flags.BoolVar(&cfg.PIIPseudonyms, "pii-pseudonyms", false, "replace personal data with consistent tokens such as [EMAIL_1] instead of placeholders")
+	flags.StringVar(&cfg.PIIReport, "pii-report", "", "file listing the number of personal data matches of each kind per file")
+	flags.StringArrayVar(&cfg.SkipIfContains, "skip-if-contains", []string{}, "skip files whose text matches this regular expression (repeatable)")
+	flags.StringVar(&cfg.Dedup, "dedup", "", "emit identical files once: reference later copies with \"[same as path]\" or skip them")
+	flags.Float64Var(&cfg.DedupNear, "dedup-near", 0, "with --dedup, also treat files at least this similar (0 to 1) as duplicates")
+	flags.Var((*sizeValue)(&cfg.MaxFileSize), "max-file-size", "skip files larger than this size (e.g. 50MB), 0 for no limit")
+	flags.Var((*limitValue)(&cfg.TruncateAt), "truncate-file-at", "keep only this much of the text of each file: a size (e.g. 64KB), or a number of lines or words (e.g. 2000lines, 500words)")
+	flags.BoolVar(&cfg.TruncateTail, "truncate-tail", false, "with --truncate-file-at, keep the end of long files as well as their start")
+	flags.StringVar(&cfg.CacheDir, "cache-dir", "", "directory keeping the text of files between runs, so that unchanged files are not extracted again")
+	flags.StringVar(&cfg.Since, "since", "", "extract only the files added or modified in the git work tree since this commit, branch or tag")
+	flags.StringVar(&cfg.Diff, "diff", "", "with --since, write a unified diff of the text of each file: only, instead of the text, or both")
+	flags.StringVar(&cfg.GitRev, "git-rev", "", "extract the files of this git commit, branch or tag from the repository of the input directory instead of the files on disk")
+	flags.BoolVar(&cfg.Watch, "watch", false, "keep running and update the output whenever files in the input directory change")
+	flags.StringVarP(&cfg.ConfigFile, "config", "c", "", "JSON configuration file")
+	flags.BoolVar(&cfg.Archives, "archives", false, "extract files inside zip, tar, tar.gz and tgz archives")
+	flags.IntVar(&cfg.ArchiveDepth, "archive-depth", ARCHIVE_DEPTH, "levels of nested archives to descend into")
+	cfg.ArchiveMaxSize = ARCHIVE_MAX_SIZE
+	flags.Var((*sizeValue)(&cfg.ArchiveMaxSize), "archive-max-size", "maximum uncompressed size read from one archive (e.g. 512MB)")
 
 	// use catchPanic to recover from any panics that might occur while parsing flags
 	err := catchPanic(func() {
@@ -48,6 +119,11 @@ func ParseCommandLineArguments(args []string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse command-line arguments: %s", err)
 	}
+	if cfg.ConfigFile != "" {
+		if err := loadConfigFile(cfg.ConfigFile, cfg); err != nil {
+			return nil, err
+		}
+	}
 	if err := validateConfig(cfg); err != nil {
 		return nil, err
 	}
@@ -78,5 +154,56 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("input directory does not exist: %s", cfg.InputDir)
 	}
 
+	switch cfg.StructuredMode {
+	case "", "flatten", "pretty":
+	default:
+		return fmt.Errorf("invalid structured mode %q, expected flatten or pretty", cfg.StructuredMode)
+	}
+
+	switch cfg.Dedup {
+	case "", "reference", "skip":
+	default:
+		return fmt.Errorf("invalid dedup mode %q, expected reference or skip", cfg.Dedup)
+	}
+	if cfg.DedupNear < 0 || cfg.DedupNear > 1 {
+		return fmt.Errorf("invalid --dedup-near value %v, expected a similarity between 0 and 1", cfg.DedupNear)
+	}
+	if cfg.DedupNear > 0 && cfg.Dedup == "" {
+		return errors.New("--dedup-near requires --dedup")
+	}
+
+	if cfg.TruncateTail && cfg.TruncateAt.N == 0 {
+		return errors.New("--truncate-tail requires --truncate-file-at")
+	}
+
+	switch cfg.Diff {
+	case "", "only", "both":
+	default:
+		return fmt.Errorf("invalid diff mode %q, expected only or both", cfg.Diff)
+	}
+	if cfg.Diff != "" && cfg.Since == "" {
+		return errors.New("--diff requires --since")
+	}
+	if cfg.GitRev != "" && cfg.Since != "" {
+		return errors.New("--git-rev and --since cannot be used together")
+	}
+	if cfg.Watch && cfg.GitRev != "" {
+		return errors.New("--watch and --git-rev cannot be used together")
+	}
+
+	for _, pattern := range cfg.SkipIfContains {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid --skip-if-contains pattern: %s", err)
+		}
+	}
+
+	if _, err := redact.PII(cfg.PII...); err != nil {
+		return err
+	}
+
+	if cfg.CommentsOnly && cfg.StripComments {
+		return errors.New("--comments-only and --strip-comments cannot be used together")
+	}
+
 	return nil
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestParseCommandLineArguments(t *testing.T) {
@@ -59,3 +60,158 @@ func compareStringSlices(s1, s2 []string) bool {
 	}
 	return true
 }
+
+func TestParseCommandLineArguments_ConfigFile(t *testing.T) {
+	if err := os.Mkdir("input", 0777); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+	defer os.RemoveAll("input")
+
+	content := `{"commands": [
+		{"extensions": [".pdf"], "command": "pdftotext -layout {} -", "timeout": "10s"},
+		{"mime_types": ["application/rtf"], "command": "pandoc -t plain --wrap='none'", "on_error": "skip"}
+	]}`
+	if err := os.WriteFile("input/config.json", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cfg, err := ParseCommandLineArguments([]string{"-d", "input", "--config", "input/config.json"})
+	if err != nil {
+		t.Fatalf("Failed to parse command-line arguments: %v", err)
+	}
+	if len(cfg.Commands) != 2 {
+		t.Fatalf("Unexpected number of commands, expected 2, got %d", len(cfg.Commands))
+	}
+
+	pdf := cfg.Commands[0]
+	if !compareStringSlices(pdf.Args, []string{"pdftotext", "-layout", "{}", "-"}) {
+		t.Errorf("Unexpected args %q", pdf.Args)
+	}
+	if pdf.Timeout.Duration != 10*time.Second || pdf.OnError != "fail" {
+		t.Errorf("Unexpected timeout or error handling: %v, %s", pdf.Timeout, pdf.OnError)
+	}
+
+	rtf := cfg.Commands[1]
+	if !compareStringSlices(rtf.Args, []string{"pandoc", "-t", "plain", "--wrap=none"}) {
+		t.Errorf("Unexpected args %q", rtf.Args)
+	}
+	if rtf.Timeout.Duration != DEFAULT_COMMAND_TIMEOUT || rtf.OnError != "skip" {
+		t.Errorf("Unexpected timeout or error handling: %v, %s", rtf.Timeout, rtf.OnError)
+	}
+
+	if err := os.WriteFile("input/config.json", []byte(`{"commands": [{"command": "cat"}]}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if _, err := ParseCommandLineArguments([]string{"-d", "input", "--config", "input/config.json"}); err == nil {
+		t.Errorf("Expected an error for a command without extensions or mime_types")
+	}
+}
+
+func TestParseCommandLineArguments_Transforms(t *testing.T) {
+	if err := os.Mkdir("input", 0777); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+	defer os.RemoveAll("input")
+
+	content := `{
+		"transforms": [{"pattern": "(\\w+)\\.corp\\.example\\.com", "replace": "$1.internal", "files": ["*.md"]}],
+		"skip_if_contains": ["@generated"]
+	}`
+	if err := os.WriteFile("input/config.json", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	args := []string{"-d", "input", "--config", "input/config.json", "--skip-if-contains", "Code generated .* DO NOT EDIT, by hand"}
+	cfg, err := ParseCommandLineArguments(args)
+	if err != nil {
+		t.Fatalf("Failed to parse command-line arguments: %v", err)
+	}
+	if len(cfg.Transforms) != 1 || cfg.Transforms[0].Regexp == nil {
+		t.Fatalf("Unexpected transforms %+v", cfg.Transforms)
+	}
+	if got := cfg.Transforms[0].Regexp.ReplaceAllString("db.corp.example.com", cfg.Transforms[0].Replace); got != "db.internal" {
+		t.Errorf("Unexpected replacement %q", got)
+	}
+	if !compareStringSlices(cfg.SkipIfContains, []string{"Code generated .* DO NOT EDIT, by hand", "@generated"}) {
+		t.Errorf("Unexpected filters %q", cfg.SkipIfContains)
+	}
+
+	if _, err := ParseCommandLineArguments([]string{"-d", "input", "--skip-if-contains", "("}); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
+
+func TestParseCommandLineArguments_PII(t *testing.T) {
+	if err := os.Mkdir("input", 0777); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+	defer os.RemoveAll("input")
+
+	cfg, err := ParseCommandLineArguments([]string{"-d", "input", "--pii", "email,ip"})
+	if err != nil {
+		t.Fatalf("Failed to parse command-line arguments: %v", err)
+	}
+	if !compareStringSlices(cfg.PII, []string{"email", "ip"}) {
+		t.Errorf("Unexpected personal data kinds %q", cfg.PII)
+	}
+
+	if _, err := ParseCommandLineArguments([]string{"-d", "input", "--pii", "email,passport"}); err == nil {
+		t.Errorf("Expected an error for an unknown personal data kind")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "4096", want: 4096},
+		{in: "512KB", want: 512 << 10},
+		{in: "1g", want: 1 << 30},
+		{in: "8589934591G", want: 8589934591 << 30},
+		{in: "8589934592G", wantErr: true},
+		{in: "9223372036854775807B", want: 9223372036854775807},
+		{in: "-1MB", wantErr: true},
+		{in: "MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "2000lines", want: Limit{N: 2000, Unit: "lines"}},
+		{in: "500 Words", want: Limit{N: 500, Unit: "words"}},
+		{in: "64KB", want: Limit{N: 64 << 10, Unit: "bytes"}},
+		{in: "4096", want: Limit{N: 4096, Unit: "bytes"}},
+		{in: "lines", wantErr: true},
+		{in: "-3words", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DEFAULT_COMMAND_TIMEOUT is the time an external command may run when its
+// configuration does not set a timeout.
+const DEFAULT_COMMAND_TIMEOUT = time.Minute
+
+// fileConfig is the content of the JSON file given with --config.
+type fileConfig struct {
+	Commands       []ExternalCommand `json:"commands"`
+	Transforms     []Transform       `json:"transforms"`
+	SkipIfContains []string          `json:"skip_if_contains"`
+}
+
+// ExternalCommand maps file extensions or media types to a program that
+// prints the text of a file on its standard output.
+type ExternalCommand struct {
+	Extensions []string `json:"extensions"` // extensions handled by the command
+	MIMETypes  []string `json:"mime_types"` // sniffed media types handled by the command
+	Command    string   `json:"command"`    // command line; "{}" is replaced by the file path, otherwise the file is piped to stdin
+	Timeout    Duration `json:"timeout"`    // maximum run time of the command
+	OnError    string   `json:"on_error"`   // "fail" (default), "skip" the file or use its raw "text"
+	Args       []string `json:"-"`          // Command split into program and arguments
+}
+
+// Transform is a regular expression replacement applied to the text of the
+// files matching Files, before it is split and written.
+type Transform struct {
+	Pattern string         `json:"pattern"` // regular expression in Go syntax
+	Replace string         `json:"replace"` // replacement text; $1 or ${name} insert submatches
+	Files   []string       `json:"files"`   // glob patterns of the files to transform, all files when empty
+	Regexp  *regexp.Regexp `json:"-"`       // compiled Pattern
+}
+
+// Duration is a time.Duration read from a JSON string such as "30s".
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// loadConfigFile reads the JSON configuration file at path into cfg.
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %s", err)
+	}
+
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %s", path, err)
+	}
+
+	for i := range fc.Commands {
+		if err := validateCommand(&fc.Commands[i]); err != nil {
+			return fmt.Errorf("config file %s: command %d: %s", path, i+1, err)
+		}
+	}
+	cfg.Commands = fc.Commands
+
+	for i := range fc.Transforms {
+		re, err := regexp.Compile(fc.Transforms[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("config file %s: transform %d: %s", path, i+1, err)
+		}
+		fc.Transforms[i].Regexp = re
+	}
+	cfg.Transforms = fc.Transforms
+	cfg.SkipIfContains = append(cfg.SkipIfContains, fc.SkipIfContains...)
+
+	return nil
+}
+
+// validateCommand checks an external command entry and fills in its defaults.
+func validateCommand(c *ExternalCommand) error {
+	if len(c.Extensions) == 0 && len(c.MIMETypes) == 0 {
+		return errors.New("no extensions or mime_types to match")
+	}
+	args, err := splitCommandLine(c.Command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("command is required")
+	}
+	c.Args = args
+
+	switch c.OnError {
+	case "":
+		c.OnError = "fail"
+	case "fail", "skip", "text":
+	default:
+		return fmt.Errorf("invalid on_error value %q", c.OnError)
+	}
+	if c.Timeout.Duration <= 0 {
+		c.Timeout.Duration = DEFAULT_COMMAND_TIMEOUT
+	}
+
+	return nil
+}
+
+// splitCommandLine splits a command line into words, honouring single and
+// double quotes and backslash escapes outside single quotes.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command %q", s)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limit is an amount of text counted in bytes, lines or words.
+type Limit struct {
+	N    int64
+	Unit string // "bytes", "lines" or "words"
+}
+
+// ParseLimit parses a limit such as "2000lines", "500 words" or "64KB". A
+// number without a "lines" or "words" suffix is a size parsed with ParseSize.
+func ParseLimit(s string) (Limit, error) {
+	lower := strings.ToLower(strings.TrimSpace(s))
+	for _, unit := range []string{"lines", "words"} {
+		if strings.HasSuffix(lower, unit) {
+			n, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(lower, unit)), 10, 64)
+			if err != nil || n < 0 {
+				return Limit{}, fmt.Errorf("invalid limit %q", s)
+			}
+			return Limit{N: n, Unit: unit}, nil
+		}
+	}
+
+	n, err := ParseSize(s)
+	if err != nil {
+		return Limit{}, fmt.Errorf("invalid limit %q", s)
+	}
+	return Limit{N: n, Unit: "bytes"}, nil
+}
+
+// String formats l as accepted by ParseLimit, or "0" for no limit.
+func (l Limit) String() string {
+	if l.N == 0 {
+		return "0"
+	}
+	if l.Unit == "bytes" {
+		return strconv.FormatInt(l.N, 10)
+	}
+	return strconv.FormatInt(l.N, 10) + l.Unit
+}
+
+// limitValue is a pflag.Value holding a Limit parsed with ParseLimit.
+type limitValue Limit
+
+func (v *limitValue) String() string {
+	return Limit(*v).String()
+}
+
+func (v *limitValue) Set(s string) error {
+	l, err := ParseLimit(s)
+	if err != nil {
+		return err
+	}
+	*v = limitValue(l)
+	return nil
+}
+
+func (v *limitValue) Type() string {
+	return "limit"
+}
@@ -0,0 +1,215 @@
+package dedup
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// shingleWords is the number of consecutive words hashed together.
+	shingleWords = 5
+	// bands and rows split a MinHash signature for locality-sensitive
+	// hashing; bands*rows is the signature length.
+	bands = 32
+	rows  = 4
+	// maxWordLen bounds the memory used for one word of the text.
+	maxWordLen = 1 << 20
+)
+
+// Index remembers the files seen in a run to find duplicates among them. It
+// is safe for concurrent use.
+type Index struct {
+	mu        sync.Mutex
+	threshold float64             // minimum estimated similarity of near duplicates, 0 to disable
+	exact     map[[32]byte]string // content hash to first path
+	paths     []string
+	sigs      [][]uint64
+	buckets   map[uint64][]int // band hash to indexes in sigs
+}
+
+// New returns an empty index. Files whose text is at least threshold
+// similar to an earlier one, as estimated by MinHash over word shingles, are
+// near duplicates; a threshold of 0 only detects identical files.
+func New(threshold float64) *Index {
+	return &Index{
+		threshold: threshold,
+		exact:     map[[32]byte]string{},
+		buckets:   map[uint64][]int{},
+	}
+}
+
+// Exact records the content read from r as the content of the file at path
+// and returns the path of an earlier file with the same content, or "".
+func (ix *Index) Exact(path string, r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return ix.ExactSum(path, sum), nil
+}
+
+// ExactSum is Exact for content whose SHA-256 hash is sum.
+func (ix *Index) ExactSum(path string, sum [32]byte) string {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if first, ok := ix.exact[sum]; ok {
+		return first
+	}
+	ix.exact[sum] = path
+	return ""
+}
+
+// Near records the text read from r as the text of the file at path and
+// returns the path of an earlier file whose text is a near duplicate, or "".
+// It always returns "" without reading r when near duplicate detection is
+// disabled.
+func (ix *Index) Near(path string, r io.Reader) (string, error) {
+	if ix.threshold <= 0 {
+		return "", nil
+	}
+	sig, err := Signature(r)
+	if err != nil {
+		return "", err
+	}
+	return ix.NearSignature(path, sig), nil
+}
+
+// NearSignature is Near for a text whose signature is sig.
+func (ix *Index) NearSignature(path string, sig []uint64) string {
+	if ix.threshold <= 0 {
+		return ""
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	keys := bandKeys(sig)
+	seen := map[int]bool{}
+	for _, key := range keys {
+		for _, i := range ix.buckets[key] {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			if similarity(sig, ix.sigs[i]) >= ix.threshold {
+				return ix.paths[i]
+			}
+		}
+	}
+
+	ix.paths = append(ix.paths, path)
+	ix.sigs = append(ix.sigs, sig)
+	for _, key := range keys {
+		ix.buckets[key] = append(ix.buckets[key], len(ix.sigs)-1)
+	}
+	return ""
+}
+
+// Signature returns the MinHash signature of the word shingles of the text
+// read from r, by which Index estimates the similarity of texts. Case and
+// whitespace are ignored.
+func Signature(r io.Reader) ([]uint64, error) {
+	sig := make([]uint64, bands*rows)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	add := func(words []string) {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words, " ")))
+		shingle := h.Sum64()
+		for j := range sig {
+			if v := mix(shingle ^ seeds[j]); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxWordLen)
+	sc.Split(scanWords)
+	window := make([]string, 0, shingleWords)
+	full := false
+	for sc.Scan() {
+		if len(window) == shingleWords {
+			copy(window, window[1:])
+			window = window[:shingleWords-1]
+		}
+		window = append(window, strings.ToLower(sc.Text()))
+		if len(window) == shingleWords {
+			add(window)
+			full = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !full {
+		add(window)
+	}
+	return sig, nil
+}
+
+// scanWords is bufio.ScanWords cutting words longer than maxWordLen.
+func scanWords(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanWords(data, atEOF)
+	if advance == 0 && token == nil && err == nil && len(data) >= maxWordLen {
+		return len(data), data, nil
+	}
+	return advance, token, err
+}
+
+// similarity returns the fraction of equal values in two signatures, an
+// estimate of the Jaccard similarity of the shingle sets.
+func similarity(a, b []uint64) float64 {
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// bandKeys returns one hash per band of sig, distinct between bands.
+func bandKeys(sig []uint64) []uint64 {
+	keys := make([]uint64, bands)
+	buf := make([]byte, 8)
+	for b := 0; b < bands; b++ {
+		h := fnv.New64a()
+		binary.LittleEndian.PutUint64(buf, uint64(b))
+		h.Write(buf)
+		for _, v := range sig[b*rows : (b+1)*rows] {
+			binary.LittleEndian.PutUint64(buf, v)
+			h.Write(buf)
+		}
+		keys[b] = h.Sum64()
+	}
+	return keys
+}
+
+// seeds holds one seed per MinHash function.
+var seeds = func() []uint64 {
+	s := make([]uint64, bands*rows)
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		x = mix(x + uint64(i))
+		s[i] = x
+	}
+	return s
+}()
+
+// mix is the SplitMix64 finalizer, a fast bijective hash of x.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,54 @@
+package dedup
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "Step %d configures the service and checks item %d. ", i, i*7)
+	}
+	doc := sb.String()
+	other := strings.Replace(doc, "configures", "removes", -1)
+
+	tests := []struct {
+		name          string
+		path          string
+		data          string
+		expectedExact string
+		expectedNear  string
+	}{
+		{name: "first file", path: "a.md", data: doc},
+		{name: "identical file", path: "vendor/a.md", data: doc, expectedExact: "a.md", expectedNear: "a.md"},
+		{name: "whitespace changes", path: "b.md", data: strings.ReplaceAll(doc, " ", "\n  "), expectedNear: "a.md"},
+		{name: "different header", path: "c.md", data: "# Copy of the docs\n\n" + doc, expectedNear: "a.md"},
+		{name: "different file", path: "d.md", data: other},
+	}
+
+	ix := New(0.8)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ix.Exact(test.path, strings.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.expectedExact {
+				t.Errorf("Unexpected exact duplicate, expected %q, got %q", test.expectedExact, got)
+			}
+			got, err = ix.Near(test.path, strings.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.expectedNear {
+				t.Errorf("Unexpected near duplicate, expected %q, got %q", test.expectedNear, got)
+			}
+		})
+	}
+
+	if got, _ := New(0).Near("a.md", strings.NewReader(doc)); got != "" {
+		t.Errorf("Near duplicates detected while disabled: %q", got)
+	}
+}
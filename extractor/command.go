@@ -0,0 +1,128 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Command describes an external program that prints the text of a file on
+// its standard output.
+type Command struct {
+	Extensions []string      // extensions handled by the command
+	MIMETypes  []string      // sniffed media types handled by the command
+	Args       []string      // program and arguments; "{}" is replaced by the file path
+	Timeout    time.Duration // maximum run time, zero for no limit
+	OnError    string        // "fail", "skip" the file or fall back to its raw "text"
+}
+
+// commandExtractor runs a Command to extract text.
+type commandExtractor struct {
+	cmd   Command
+	match Matcher
+}
+
+// NewCommand returns an extractor that runs c for the files it matches. When
+// no argument contains "{}", the file content is piped to the command.
+func NewCommand(c Command) Extractor {
+	return &commandExtractor{
+		cmd:   c,
+		match: MatchAny(MatchExtension(c.Extensions...), MatchMIME(c.MIMETypes...)),
+	}
+}
+
+func (e *commandExtractor) Name() string {
+	return "command:" + filepath.Base(e.cmd.Args[0])
+}
+
+func (e *commandExtractor) Match(f *File) bool {
+	return e.match(f)
+}
+
+func (e *commandExtractor) Extract(f *File) (*Result, error) {
+	text, err := e.run(f)
+	if err == nil {
+		return &Result{Text: text}, nil
+	}
+
+	switch e.cmd.OnError {
+	case "skip":
+		return &Result{Metadata: map[string]string{"error": err.Error(), "skipped": "true"}}, nil
+	case "text":
+		result, textErr := extractText(f)
+		if textErr != nil {
+			return nil, textErr
+		}
+		result.Metadata = map[string]string{"error": err.Error()}
+		return result, nil
+	}
+	return nil, err
+}
+
+// run executes the command for f and returns its standard output.
+func (e *commandExtractor) run(f *File) (string, error) {
+	ctx := context.Background()
+	if e.cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.cmd.Timeout)
+		defer cancel()
+	}
+
+	piped := true
+	for _, arg := range e.cmd.Args {
+		if strings.Contains(arg, "{}") {
+			piped = false
+		}
+	}
+
+	args := e.cmd.Args
+	if !piped {
+		path, cleanup, err := f.localPath()
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+
+		args = make([]string, len(e.cmd.Args))
+		for i, arg := range e.cmd.Args {
+			args[i] = strings.ReplaceAll(arg, "{}", path)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if piped {
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		cmd.Stdin = rc
+	}
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s", args[0], e.cmd.Timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s: %s", args[0], err, firstLine(msg))
+		}
+		return "", fmt.Errorf("%s: %s", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
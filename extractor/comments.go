@@ -0,0 +1,446 @@
+package extractor
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CommentOptions controls the handling of source code comments.
+type CommentOptions struct {
+	Only  bool // extract only the comments and docstrings of source files
+	Strip bool // remove comments, blank lines and trailing whitespace from source files
+}
+
+// commentSyntax describes how comments and strings are written in a
+// language.
+type commentSyntax struct {
+	line       []string    // line comment markers
+	block      [][2]string // block comment delimiters
+	quotes     []string    // string delimiters, longer ones first
+	wordHash   bool        // "#" starts a comment only at the start of a word
+	docstrings bool        // string statements after a definition are docstrings
+}
+
+var (
+	cSyntax = &commentSyntax{
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []string{`"`, `'`},
+	}
+	jsSyntax = &commentSyntax{
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []string{`"`, `'`, "`"},
+	}
+	rustSyntax = &commentSyntax{
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []string{`"`},
+	}
+	cssSyntax = &commentSyntax{
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []string{`"`, `'`},
+	}
+	phpSyntax = &commentSyntax{
+		line:   []string{"//", "#"},
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []string{`"`, `'`},
+	}
+	pythonSyntax = &commentSyntax{
+		line:       []string{"#"},
+		quotes:     []string{`"""`, `'''`, `"`, `'`},
+		docstrings: true,
+	}
+	shellSyntax = &commentSyntax{
+		line:     []string{"#"},
+		quotes:   []string{`"`, `'`},
+		wordHash: true,
+	}
+	sqlSyntax = &commentSyntax{
+		line:   []string{"--"},
+		block:  [][2]string{{"/*", "*/"}},
+		quotes: []string{`'`, `"`},
+	}
+)
+
+// commentSyntaxes maps source file extensions to their comment syntax.
+var commentSyntaxes = map[string]*commentSyntax{
+	".c": cSyntax, ".h": cSyntax, ".cc": cSyntax, ".cpp": cSyntax, ".cxx": cSyntax,
+	".hpp": cSyntax, ".hh": cSyntax, ".java": cSyntax, ".cs": cSyntax,
+	".kt": cSyntax, ".kts": cSyntax, ".scala": cSyntax, ".swift": cSyntax,
+	".m": cSyntax, ".mm": cSyntax, ".dart": cSyntax, ".groovy": cSyntax,
+	".js": jsSyntax, ".jsx": jsSyntax, ".mjs": jsSyntax, ".cjs": jsSyntax,
+	".ts": jsSyntax, ".tsx": jsSyntax, ".go": jsSyntax,
+	".rs":  rustSyntax,
+	".css": cssSyntax, ".scss": cSyntax, ".less": cSyntax,
+	".php": phpSyntax,
+	".py":  pythonSyntax, ".pyw": pythonSyntax, ".pyi": pythonSyntax,
+	".sh": shellSyntax, ".bash": shellSyntax, ".zsh": shellSyntax, ".ksh": shellSyntax,
+	".rb": shellSyntax, ".pl": shellSyntax, ".pm": shellSyntax,
+	".sql": sqlSyntax,
+}
+
+// commentExtensions returns the extensions of the files whose comments can
+// be extracted, in sorted order.
+func commentExtensions() []string {
+	exts := make([]string, 0, len(commentSyntaxes))
+	for ext := range commentSyntaxes {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// comment is a comment or docstring found in source code.
+type comment struct {
+	start, end int    // byte offsets of the comment in the source
+	line       int    // line number of its start, from 1
+	marker     string // line comment marker, "" for block comments and docstrings
+	trailing   bool   // code precedes it on its line
+	docstring  bool
+	symbol     string // with docstrings, the def or class documented, or "module"
+	text       string // the comment without its markers
+}
+
+// scanComments returns the comments and docstrings of src in order. String
+// literals are skipped so that comment markers inside them are ignored.
+func scanComments(src string, syn *commentSyntax) []comment {
+	comments, _ := scanSource(src, syn)
+	return comments
+}
+
+// scanSource returns the comments and docstrings of src and the byte ranges
+// of its string literals, in order. Docstrings are reported as both.
+func scanSource(src string, syn *commentSyntax) ([]comment, [][2]int) {
+	var comments []comment
+	var literals [][2]int
+	var python pythonLines
+	line := 1
+	lineStart := 0
+	i := 0
+	if strings.HasPrefix(src, "#!") {
+		// Skip the interpreter line.
+		for i < len(src) && src[i] != '\n' {
+			i++
+		}
+	}
+
+	// codeBefore reports whether the current line has code before pos.
+	codeBefore := func(pos int) bool {
+		return strings.TrimSpace(src[lineStart:pos]) != ""
+	}
+	// advance moves i to end, counting the lines passed.
+	advance := func(end int) {
+		for ; i < end; i++ {
+			if src[i] == '\n' {
+				if syn.docstrings {
+					python.add(src[lineStart:i])
+				}
+				line++
+				lineStart = i + 1
+			}
+		}
+	}
+
+scan:
+	for i < len(src) {
+		if src[i] == '\n' {
+			advance(i + 1)
+			continue
+		}
+		rest := src[i:]
+
+		for _, delims := range syn.block {
+			if !strings.HasPrefix(rest, delims[0]) {
+				continue
+			}
+			// An unterminated comment runs to the end of the source.
+			end, textEnd := len(src), len(src)
+			if n := strings.Index(rest[len(delims[0]):], delims[1]); n >= 0 {
+				textEnd = i + len(delims[0]) + n
+				end = textEnd + len(delims[1])
+			}
+			c := comment{start: i, end: end, line: line, trailing: codeBefore(i)}
+			c.text = blockCommentText(src[i+len(delims[0]) : textEnd])
+			comments = append(comments, c)
+			advance(end)
+			continue scan
+		}
+
+		for _, marker := range syn.line {
+			if !strings.HasPrefix(rest, marker) {
+				continue
+			}
+			if marker == "#" && syn.wordHash && i > 0 && !isSpace(src[i-1]) {
+				continue
+			}
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i
+			}
+			c := comment{start: i, end: end, line: line, marker: marker, trailing: codeBefore(i)}
+			text := strings.TrimLeft(src[i+len(marker):end], marker[len(marker)-1:]+"!")
+			c.text = strings.TrimRight(strings.TrimPrefix(text, " "), " \t\r")
+			comments = append(comments, c)
+			advance(end)
+			continue scan
+		}
+
+		for _, quote := range syn.quotes {
+			if !strings.HasPrefix(rest, quote) {
+				continue
+			}
+			end, closed := stringEnd(src, i, quote)
+			if syn.docstrings && len(quote) == 3 && !codeBefore(i) && python.docstringPosition() {
+				textEnd := end
+				if closed {
+					textEnd -= len(quote)
+				}
+				comments = append(comments, comment{
+					start: i, end: end, line: line, docstring: true, symbol: python.symbol(),
+					text: docstringText(src[i+len(quote) : textEnd]),
+				})
+			}
+			literals = append(literals, [2]int{i, end})
+			advance(end)
+			continue scan
+		}
+
+		advance(i + 1)
+	}
+	return comments, literals
+}
+
+// stringEnd returns the offset just past the string literal opened by quote
+// at start, and whether the literal is closed by quote. Single-character
+// quotes other than backticks end at a newline, and unterminated literals
+// at the end of src.
+func stringEnd(src string, start int, quote string) (int, bool) {
+	for i := start + len(quote); i < len(src); i++ {
+		switch {
+		case src[i] == '\\' && quote != "`":
+			i++
+		case strings.HasPrefix(src[i:], quote):
+			return i + len(quote), true
+		case src[i] == '\n' && len(quote) == 1 && quote != "`":
+			return i, false
+		}
+	}
+	return len(src), false
+}
+
+// pythonLines follows the lines of Python source as they are scanned,
+// keeping what tells whether a string statement on the next line is a
+// docstring, so that the source before it is not scanned again.
+type pythonLines struct {
+	code    bool   // a line of code has been seen
+	colon   bool   // the last line of code ends with ":"
+	def     string // def or class whose signature the last line of code ends, such as "def parse"
+	lineDef string // the same for the last line, code or not
+	cont    bool   // the last line ends with "," or "(", continuing a signature
+}
+
+// add follows line, the next line of the source.
+func (p *pythonLines) add(line string) {
+	def := ""
+	if m := pythonDefinition.FindStringSubmatch(line); m != nil {
+		def = m[1] + " " + m[2]
+	} else if p.cont {
+		def = p.lineDef
+	}
+	trimmed := strings.TrimSpace(line)
+	p.lineDef = def
+	p.cont = strings.HasSuffix(trimmed, ",") || strings.HasSuffix(trimmed, "(")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return
+	}
+	p.code, p.colon, p.def = true, strings.HasSuffix(trimmed, ":"), def
+}
+
+// docstringPosition reports whether a string statement on the next line is
+// a docstring: the first statement of the module or of the body of a def
+// or class.
+func (p *pythonLines) docstringPosition() bool {
+	return !p.code || (p.colon && p.def != "")
+}
+
+// symbol returns the symbol documented by a docstring on the next line:
+// the enclosing def or class, or "module".
+func (p *pythonLines) symbol() string {
+	if p.def == "" {
+		return "module"
+	}
+	return p.def
+}
+
+// isSpace reports whether c is an ASCII space character.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// blockCommentText returns the text of a block comment without the leading
+// "*" of its lines.
+func blockCommentText(s string) string {
+	s = strings.TrimLeft(s, "*!")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			line = strings.TrimPrefix(line[1:], " ")
+		}
+		lines[i] = line
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// docstringText returns the text of a docstring with its common indentation
+// removed.
+func docstringText(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+var (
+	pythonDefinition = regexp.MustCompile(`^\s*(?:async\s+)?(def|class)\s+([A-Za-z_]\w*)`)
+	declarationName  = regexp.MustCompile(`^\s*(?:(?:export|public|private|protected|static|async|abstract|final|default|pub|virtual|inline|extern|unsafe|override|internal|open|sealed|data|readonly)\s+)*` +
+		`(func|function|def|class|struct|interface|enum|type|fn|trait|impl|module|namespace|union|object|macro|const|let|var)\s+([A-Za-z_$][\w$.]*)`)
+	sqlObjectName = regexp.MustCompile(`(?i)^\s*create\s+(?:or\s+replace\s+)?(table|view|function|procedure|index|trigger|schema|type|sequence)\s+(?:if\s+not\s+exists\s+)?([\w."]+)`)
+	functionName  = regexp.MustCompile(`^\s*(?:function\s+)?[\w\s*&:<>,\[\]]*?\b([A-Za-z_]\w*)\s*\([^;]*$`)
+)
+
+// notFunctions lists keywords that look like function calls.
+var notFunctions = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true,
+	"catch": true, "sizeof": true, "elif": true, "until": true,
+}
+
+// declaredSymbol returns the symbol declared on a line of code, such as
+// "class Parser" or "parse()", or "" if it declares none.
+func declaredSymbol(line string) string {
+	if m := sqlObjectName.FindStringSubmatch(line); m != nil {
+		return strings.ToLower(m[1]) + " " + strings.Trim(m[2], `"`)
+	}
+	if m := declarationName.FindStringSubmatch(line); m != nil {
+		return m[1] + " " + m[2]
+	}
+	if m := functionName.FindStringSubmatch(line); m != nil && !notFunctions[m[1]] {
+		return m[1] + "()"
+	}
+	return ""
+}
+
+// extractComments returns the comments and docstrings of a source file, each
+// preceded by its line number and the symbol it documents.
+func extractComments(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	src := string(data)
+
+	var entries []commentEntry
+	if f.Ext == ".go" {
+		entries, err = goComments(f.Path, src)
+	}
+	if f.Ext != ".go" || err != nil {
+		entries = lexedComments(src, commentSyntaxes[f.Ext])
+	}
+
+	parts := make([]string, 0, len(entries))
+	for i, e := range entries {
+		part := "line " + strconv.Itoa(e.line)
+		if e.symbol != "" {
+			part += ", " + e.symbol
+		}
+		part += "\n" + e.text
+		if i < len(entries)-1 {
+			part += "\n\n"
+		}
+		parts = append(parts, part)
+	}
+
+	return &Result{
+		Text:     strings.Join(parts, ""),
+		Metadata: map[string]string{"comments": strconv.Itoa(len(entries))},
+		Parts:    parts,
+	}, nil
+}
+
+// commentEntry is a comment as emitted in the extracted text.
+type commentEntry struct {
+	line   int
+	symbol string
+	text   string
+}
+
+// lexedComments returns the comments of src found with syn. Consecutive
+// line comments are merged, and comments directly above a declaration are
+// attributed to it.
+func lexedComments(src string, syn *commentSyntax) []commentEntry {
+	var entries []commentEntry
+	var prev *comment
+	for _, c := range scanComments(src, syn) {
+		c := c
+		if strings.TrimSpace(c.text) == "" {
+			continue
+		}
+		merge := prev != nil && c.marker != "" && prev.marker == c.marker && !c.trailing && !prev.trailing &&
+			strings.TrimSpace(src[prev.end:c.start]) == "" && strings.Count(src[prev.end:c.start], "\n") == 1
+		if merge {
+			last := &entries[len(entries)-1]
+			last.text += "\n" + c.text
+		} else {
+			entries = append(entries, commentEntry{line: c.line, text: c.text})
+		}
+		prev = &c
+
+		last := &entries[len(entries)-1]
+		switch {
+		case c.docstring:
+			last.symbol = c.symbol
+		case !c.trailing:
+			last.symbol = declaredSymbol(nextCodeLine(src, c.end))
+		}
+	}
+	return entries
+}
+
+// nextCodeLine returns the code following a comment that ends at end: the
+// rest of its line, or the next line if the rest is blank. A blank line
+// after the comment detaches it from the code, yielding "".
+func nextCodeLine(src string, end int) string {
+	rest := src[end:]
+	i := strings.IndexByte(rest, '\n')
+	if i < 0 {
+		return rest
+	}
+	if strings.TrimSpace(rest[:i]) != "" {
+		return rest[:i]
+	}
+	rest = rest[i+1:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
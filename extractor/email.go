@@ -0,0 +1,244 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// emailHeaders lists the message headers included in the text, in order.
+var emailHeaders = []string{"From", "To", "Cc", "Date", "Subject"}
+
+// extractEmail returns the headers and body text of an RFC 5322 message.
+func extractEmail(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	text, err := messageText(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: text}, nil
+}
+
+// extractMbox splits an mbox file into its messages and returns the text of
+// each in order.
+func extractMbox(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := splitMbox(data)
+	texts := make([]string, 0, len(messages))
+	for _, raw := range messages {
+		text, err := messageText(raw)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+	}
+
+	return &Result{
+		Text:     strings.Join(texts, "\n\n"),
+		Metadata: map[string]string{"messages": strconv.Itoa(len(texts))},
+	}, nil
+}
+
+// mboxQuotedFrom matches body lines escaped by the mbox writer.
+var mboxQuotedFrom = regexp.MustCompile(`^>+From `)
+
+// splitMbox returns the messages of an mbox file without their "From "
+// separator lines, undoing the ">From " escaping of body lines.
+func splitMbox(data []byte) [][]byte {
+	var messages [][]byte
+	var current *bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "From ") {
+			if current != nil {
+				messages = append(messages, current.Bytes())
+			}
+			current = &bytes.Buffer{}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if mboxQuotedFrom.MatchString(line) {
+			line = line[1:]
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	if current != nil {
+		messages = append(messages, current.Bytes())
+	}
+	return messages
+}
+
+// messageText returns the main headers of a raw message followed by its
+// body, preferring text/plain parts over text/html ones.
+func messageText(raw []byte) (string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	dec := &mime.WordDecoder{}
+	for _, name := range emailHeaders {
+		value := msg.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		if decoded, err := dec.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		sb.WriteString(name + ": " + value + "\n")
+	}
+
+	body, _, err := partText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return "", err
+	}
+	if body != "" {
+		sb.WriteString("\n" + body)
+	}
+
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// partText returns the text of a message body or MIME part with the given
+// content type and transfer encoding, and whether it is a text/plain part.
+// Attachments and non-text parts yield no text.
+func partText(contentType, transferEncoding string, r io.Reader) (string, bool, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	r = decodeTransferEncoding(transferEncoding, r)
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return multipartText(mediaType, params["boundary"], r)
+	case mediaType == "message/rfc822":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", false, err
+		}
+		text, err := messageText(data)
+		return text, false, err
+	case mediaType == "text/plain":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimSpace(decodeCharset(data, params["charset"])), true, nil
+	case mediaType == "text/html":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", false, err
+		}
+		text, err := HTMLToText(strings.NewReader(decodeCharset(data, params["charset"])), HTMLOptions{})
+		return text, false, err
+	}
+	return "", false, nil
+}
+
+// multipartText returns the text of a multipart body. Alternatives yield
+// their text/plain version when there is one; other multiparts yield the
+// text of all their inline parts.
+func multipartText(mediaType, boundary string, r io.Reader) (string, bool, error) {
+	mr := multipart.NewReader(r, boundary)
+	var texts []string
+	alternative := ""
+	plain := false
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+			continue
+		}
+
+		contentType := part.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+		// multipart.Part decodes quoted-printable itself and removes the header.
+		text, isPlain, err := partText(contentType, part.Header.Get("Content-Transfer-Encoding"), part)
+		if err != nil {
+			return "", false, err
+		}
+		if text == "" {
+			continue
+		}
+
+		if mediaType == "multipart/alternative" {
+			if alternative == "" || (isPlain && !plain) {
+				alternative, plain = text, isPlain
+			}
+			continue
+		}
+		texts = append(texts, text)
+		plain = plain || isPlain
+	}
+
+	if mediaType == "multipart/alternative" {
+		return alternative, plain, nil
+	}
+	return strings.Join(texts, "\n\n"), plain, nil
+}
+
+// decodeTransferEncoding wraps r to undo a base64 or quoted-printable
+// Content-Transfer-Encoding.
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// decodeCharset converts text in the given charset to UTF-8. Latin-1 and
+// Windows-1252 text is decoded with its code page; other charsets are
+// assumed UTF-8 compatible.
+func decodeCharset(data []byte, charset string) string {
+	var codePage *charmap.Charmap
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		codePage = charmap.ISO8859_1
+	case "windows-1252", "cp1252":
+		codePage = charmap.Windows1252
+	default:
+		return string(data)
+	}
+	text, err := codePage.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
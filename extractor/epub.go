@@ -0,0 +1,103 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// epubContainer is the META-INF/container.xml document pointing at the
+// package (OPF) file of an EPUB.
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the subset of an OPF package document needed to read the
+// chapters in order.
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// extractEPUB returns the text of an EPUB e-book, converting the XHTML
+// chapters listed in the OPF spine to text in reading order.
+func extractEPUB(f *File) (*Result, error) {
+	zr, err := openZip(f)
+	if err != nil {
+		return nil, err
+	}
+	text, err := epubText(zr)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: text}, nil
+}
+
+// epubText walks the spine of the EPUB in zr and returns its chapters as text.
+func epubText(zr *zip.Reader) (string, error) {
+	data, err := readZipEntry(zr, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", err
+	}
+	if len(container.Rootfiles) == 0 {
+		return "", errors.New("epub container has no rootfile")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	data, err = readZipEntry(zr, opfPath)
+	if err != nil {
+		return "", err
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return "", err
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	var chapters []string
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		name, err := url.PathUnescape(href)
+		if err != nil {
+			name = href
+		}
+		data, err := readZipEntry(zr, path.Join(path.Dir(opfPath), name))
+		if err != nil {
+			return "", err
+		}
+		text, err := HTMLToText(bytes.NewReader(data), HTMLOptions{})
+		if err != nil {
+			return "", err
+		}
+		if text != "" {
+			chapters = append(chapters, text)
+		}
+	}
+
+	return strings.Join(chapters, "\n\n"), nil
+}
@@ -0,0 +1,222 @@
+package extractor
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is the number of leading bytes read for content sniffing.
+const sniffLen = 512
+
+// File describes an input file offered to the extractors.
+type File struct {
+	Path   string // path of the file, used for reporting
+	Ext    string // lower-case extension of Path, including the dot
+	Header []byte // leading bytes of the content, for magic number checks
+	MIME   string // media type sniffed from Header, without parameters
+
+	open     func() (io.ReadCloser, error)
+	diskPath string // path of the content on disk, empty for in-memory files
+}
+
+// NewFile returns a File for the file at path on disk.
+func NewFile(path string) (*File, error) {
+	f, err := newFile(path, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.diskPath = path
+	return f, nil
+}
+
+// NewFileFromBytes returns a File reporting path whose content is data, such
+// as an entry read from an archive.
+func NewFileFromBytes(path string, data []byte) (*File, error) {
+	return newFile(path, func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// NewFileFunc returns a File reporting path whose content is read through
+// open each time it is needed, such as content generated on the fly.
+func NewFileFunc(path string, open func() (io.ReadCloser, error)) (*File, error) {
+	return newFile(path, open)
+}
+
+// newFile returns a File reporting path whose content is read through open.
+func newFile(path string, open func() (io.ReadCloser, error)) (*File, error) {
+	rc, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(rc, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	header = header[:n]
+
+	mime := http.DetectContentType(header)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+
+	return &File{
+		Path:   path,
+		Ext:    strings.ToLower(filepath.Ext(path)),
+		Header: header,
+		MIME:   mime,
+		open:   open,
+	}, nil
+}
+
+// Open returns a reader over the content of the file.
+func (f *File) Open() (io.ReadCloser, error) {
+	return f.open()
+}
+
+// ReadAll returns the whole content of the file.
+func (f *File) ReadAll() ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// localPath returns a path on disk holding the content of f, copying
+// in-memory content to a temporary file removed by the returned function.
+func (f *File) localPath() (string, func(), error) {
+	if f.diskPath != "" {
+		return f.diskPath, func() {}, nil
+	}
+
+	tmp, err := ioutil.TempFile("", "textractor-*"+f.Ext)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	rc, err := f.Open()
+	if err == nil {
+		_, err = io.Copy(tmp, rc)
+		rc.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// Result is the text extracted from a file together with metadata about it.
+type Result struct {
+	Text     string
+	Metadata map[string]string
+
+	// Parts optionally splits Text, after Header, into pieces at whose
+	// boundaries it may be cut when it does not fit in one output file, so
+	// that Text == Header + strings.Join(Parts, ""). Header is repeated at
+	// the start of every cut piece.
+	Header string
+	Parts  []string
+}
+
+// Extractor converts the files of one format to text.
+type Extractor interface {
+	// Name identifies the extractor in metadata and error messages.
+	Name() string
+	// Match reports whether the extractor handles the file.
+	Match(f *File) bool
+	// Extract returns the text and metadata of the file.
+	Extract(f *File) (*Result, error)
+}
+
+// Streamer is implemented by extractors that can return the text of some
+// files as a stream, so that it is never held in memory as a whole. The
+// stream has no metadata and is not split into parts.
+type Streamer interface {
+	// Stream returns a reader of the text of the file, or nil when the
+	// file has to be extracted with Extract.
+	Stream(f *File) (io.ReadCloser, error)
+}
+
+// Matcher reports whether a file is of a given format.
+type Matcher func(f *File) bool
+
+// MatchExtension matches files with one of the given extensions, compared
+// case-insensitively. The leading dot is optional.
+func MatchExtension(exts ...string) Matcher {
+	return func(f *File) bool {
+		for _, ext := range exts {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			if strings.EqualFold(f.Ext, ext) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMIME matches files whose sniffed media type is one of the given types.
+func MatchMIME(types ...string) Matcher {
+	return func(f *File) bool {
+		for _, t := range types {
+			if strings.EqualFold(f.MIME, t) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMagic matches files whose content contains magic at the given offset.
+func MatchMagic(offset int, magic []byte) Matcher {
+	return func(f *File) bool {
+		return len(f.Header) >= offset && bytes.HasPrefix(f.Header[offset:], magic)
+	}
+}
+
+// MatchAny matches files accepted by at least one of the matchers.
+func MatchAny(matchers ...Matcher) Matcher {
+	return func(f *File) bool {
+		for _, m := range matchers {
+			if m(f) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// funcExtractor is an Extractor built from a matcher and a function.
+type funcExtractor struct {
+	name    string
+	match   Matcher
+	extract func(f *File) (*Result, error)
+}
+
+// New returns an Extractor named name that handles the files accepted by
+// match using extract.
+func New(name string, match Matcher, extract func(f *File) (*Result, error)) Extractor {
+	return &funcExtractor{name: name, match: match, extract: extract}
+}
+
+func (e *funcExtractor) Name() string                     { return e.name }
+func (e *funcExtractor) Match(f *File) bool               { return e.match(f) }
+func (e *funcExtractor) Extract(f *File) (*Result, error) { return e.extract(f) }
@@ -0,0 +1,1002 @@
+package extractor
+
+import (
+	"archive/zip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeZip creates a zip archive at path containing the given entries in order.
+func writeZip(t *testing.T, path string, entries [][2]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		w, err := zw.Create(e[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractOpenDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name: "text document",
+			content: `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:text>
+<text:h text:outline-level="1">Title</text:h>
+<text:p>Hello<text:s text:c="2"/>world<text:tab/>tabbed<text:line-break/>next <text:span>line</text:span></text:p>
+</office:text></office:body></office:document-content>`,
+			expected: "Title\nHello  world\ttabbed\nnext line",
+		},
+		{
+			name: "spreadsheet",
+			content: `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0">
+<office:body><office:spreadsheet><table:table table:name="Sheet1">
+<table:table-row><table:table-cell><text:p>Name</text:p></table:table-cell><table:table-cell><text:p>Age</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell><text:p>Ada</text:p></table:table-cell><table:table-cell><text:p>36</text:p></table:table-cell><table:table-cell table:number-columns-repeated="1000"/></table:table-row>
+</table:table></office:spreadsheet></office:body></office:document-content>`,
+			expected: "Name\tAge\nAda\t36",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "doc.odt")
+			writeZip(t, path, [][2]string{
+				{"mimetype", "application/vnd.oasis.opendocument.text"},
+				{"content.xml", test.content},
+			})
+
+			result, err := NewRegistry(Options{}).ExtractFile(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractEPUB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.epub")
+	writeZip(t, path, [][2]string{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`},
+		{"OEBPS/content.opf", `<package>
+<manifest>
+<item id="c1" href="chapter%201.xhtml" media-type="application/xhtml+xml"/>
+<item id="c2" href="text/c2.xhtml" media-type="application/xhtml+xml"/>
+<item id="css" href="style.css" media-type="text/css"/>
+</manifest>
+<spine><itemref idref="c2"/><itemref idref="c1"/></spine>
+</package>`},
+		{"OEBPS/chapter 1.xhtml", `<html><head><title>Ignored</title></head><body><h1>Second</h1><p>The&nbsp;end.</p></body></html>`},
+		{"OEBPS/text/c2.xhtml", `<html><body><h1>First</h1><p>Once upon<br/>a time.</p><script>var x;</script></body></html>`},
+	})
+
+	result, err := NewRegistry(Options{}).ExtractFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "First\n\nOnce upon\na time.\n\nSecond\n\nThe end."
+	if result.Text != expected {
+		t.Errorf("Unexpected text, expected %q, got %q", expected, result.Text)
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		opts     HTMLOptions
+		expected string
+	}{
+		{
+			name:     "scripts and styles dropped",
+			html:     `<html><head><style>p{color:red}</style><title>T</title></head><body><script>alert(1)</script><p>Hello &amp; <b>welcome</b></p></body></html>`,
+			expected: "Hello & welcome",
+		},
+		{
+			name:     "blocks and line breaks",
+			html:     `<h1>Title</h1><p>First<br>second</p><div>Third</div>`,
+			expected: "Title\n\nFirst\nsecond\n\nThird",
+		},
+		{
+			name:     "lists",
+			html:     `<ul><li>one</li><li>two<ol start="3"><li>three</li></ol></li></ul>`,
+			expected: "- one\n- two\n  3. three",
+		},
+		{
+			name:     "tables",
+			html:     `<table><thead><tr><th>Name</th><th>Age</th></tr></thead><tbody><tr><td>Ada</td><td>36</td></tr></tbody></table>`,
+			expected: "Name | Age\nAda | 36",
+		},
+		{
+			name:     "links dropped",
+			html:     `<p>See <a href="https://example.com">the docs</a>.</p>`,
+			expected: "See the docs.",
+		},
+		{
+			name:     "links kept",
+			html:     `<p>See <a href="https://example.com">the docs</a> or <a href="#top">top</a>.</p>`,
+			opts:     HTMLOptions{KeepLinks: true},
+			expected: "See the docs (https://example.com) or top.",
+		},
+		{
+			name:     "raw",
+			html:     `<p>a &amp; b</p>`,
+			opts:     HTMLOptions{Raw: true},
+			expected: `<p>a &amp; b</p>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := HTMLToText(strings.NewReader(test.html), test.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"notes.txt":  "plain notes",
+		"page":       "<!DOCTYPE html><html><body><p>sniffed</p></body></html>",
+		"data.upper": "shout",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewRegistry(Options{})
+	r.Add(New("upper", MatchExtension("upper"), func(f *File) (*Result, error) {
+		data, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Text: strings.ToUpper(string(data))}, nil
+	}))
+
+	tests := []struct {
+		name      string
+		file      string
+		text      string
+		extractor string
+	}{
+		{name: "fallback", file: "notes.txt", text: "plain notes", extractor: "text"},
+		{name: "mime sniffing", file: "page", text: "sniffed", extractor: "html"},
+		{name: "added extractor", file: "data.upper", text: "SHOUT", extractor: "upper"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := r.ExtractFile(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.text {
+				t.Errorf("Unexpected text, expected %q, got %q", test.text, result.Text)
+			}
+			if result.Metadata["extractor"] != test.extractor {
+				t.Errorf("Unexpected extractor, expected %q, got %q", test.extractor, result.Metadata["extractor"])
+			}
+		})
+	}
+}
+
+func TestCommandExtractor(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "doc.ext")
+	if err := os.WriteFile(path, []byte("raw content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		command   Command
+		text      string
+		expectErr bool
+	}{
+		{
+			name:    "file piped",
+			command: Command{Args: []string{"tr", "a-z", "A-Z"}},
+			text:    "RAW CONTENT",
+		},
+		{
+			name:    "file as argument",
+			command: Command{Args: []string{"sh", "-c", "echo converted $0", "{}"}},
+			text:    "converted " + path + "\n",
+		},
+		{
+			name:      "failure",
+			command:   Command{Args: []string{"sh", "-c", "echo broken >&2; exit 3"}},
+			expectErr: true,
+		},
+		{
+			name:      "timeout",
+			command:   Command{Args: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond},
+			expectErr: true,
+		},
+		{
+			name:    "failure skipped",
+			command: Command{Args: []string{"false"}, OnError: "skip"},
+			text:    "",
+		},
+		{
+			name:    "failure falls back to text",
+			command: Command{Args: []string{"false"}, OnError: "text"},
+			text:    "raw content",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.command.Extensions = []string{".ext"}
+			r := NewRegistry(Options{Commands: []Command{test.command}})
+
+			result, err := r.ExtractFile(path)
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got text %q", result.Text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.text {
+				t.Errorf("Unexpected text, expected %q, got %q", test.text, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractNotebook(t *testing.T) {
+	content := `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Analysis\n", "Load the data."]},
+  {"cell_type": "code", "execution_count": 1, "metadata": {}, "source": "print(1 + 1)\nfig", "outputs": [
+   {"output_type": "stream", "name": "stdout", "text": ["2\n"]},
+   {"output_type": "display_data", "data": {"image/png": "iVBORw0KGgo=", "text/plain": ["<Figure>"]}, "metadata": {}},
+   {"output_type": "error", "ename": "NameError", "evalue": "name 'x' is not defined", "traceback": ["\u001b[0;31m..."]}
+  ]}
+ ],
+ "metadata": {}, "nbformat": 4, "nbformat_minor": 5
+}`
+	path := filepath.Join(t.TempDir(), "analysis.ipynb")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     NotebookOptions
+		expected string
+	}{
+		{
+			name:     "cells only",
+			expected: "# %% [markdown]\n# Analysis\nLoad the data.\n\n# %% [code]\nprint(1 + 1)\nfig",
+		},
+		{
+			name:     "with outputs",
+			opts:     NotebookOptions{Outputs: true},
+			expected: "# %% [markdown]\n# Analysis\nLoad the data.\n\n# %% [code]\nprint(1 + 1)\nfig\n# Output:\n2\n<Figure>\nNameError: name 'x' is not defined",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := NewRegistry(Options{Notebook: test.opts}).ExtractFile(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractEmail(t *testing.T) {
+	alternative := "From: Ada <ada@example.com>\r\n" +
+		"To: support@example.com\r\n" +
+		"Date: Mon, 2 Jan 2006 15:04:05 +0000\r\n" +
+		"Subject: =?UTF-8?Q?Caf=C3=A9_order?=\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n" +
+		"\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n" +
+		"\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<p>HTML version</p>\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"One caf=C3=A9 au lait,=\r\n" +
+		" please.\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=invoice.pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQK\r\n" +
+		"--outer--\r\n"
+
+	htmlOnly := "From: bob@example.com\n" +
+		"Subject: Re: order\n" +
+		"Content-Type: text/html\n" +
+		"Content-Transfer-Encoding: base64\n" +
+		"\n" +
+		"PHA+VGhhbmtzITwvcD4=\n"
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"ticket.eml":  alternative,
+		"latin1.eml":  "Subject: Order\nContent-Type: text/plain; charset=iso-8859-1\n\nCaf\xe9 \xbd price.\n",
+		"windows.eml": "Subject: Order\nContent-Type: text/plain; charset=windows-1252\n\n\x93Caf\xe9\x94 \x80 5 \x96 \x85\n",
+		"archive.mbox": "From ada@example.com Mon Jan  2 15:04:05 2006\n" + strings.ReplaceAll(alternative, "\r\n", "\n") +
+			"\nFrom bob@example.com Mon Jan  2 16:04:05 2006\n" + htmlOnly,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := "From: Ada <ada@example.com>\nTo: support@example.com\nDate: Mon, 2 Jan 2006 15:04:05 +0000\nSubject: Café order\n\nOne café au lait, please."
+	second := "From: bob@example.com\nSubject: Re: order\n\nThanks!"
+
+	tests := []struct {
+		name     string
+		file     string
+		expected string
+	}{
+		{name: "eml", file: "ticket.eml", expected: first},
+		{name: "mbox", file: "archive.mbox", expected: first + "\n\n" + second},
+		{name: "latin1", file: "latin1.eml", expected: "Subject: Order\n\nCafé ½ price."},
+		{name: "windows-1252", file: "windows.eml", expected: "Subject: Order\n\n“Café” € 5 – …"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := NewRegistry(Options{}).ExtractFile(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractStructured(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.json":   `{"name": "api", "replicas": 3, "tags": ["a", "b", "c"], "env": {"LOG.LEVEL": "debug", "empty": []}, "note": null}`,
+		"app.yaml":   "name: api\ndescription: |\n  Serves requests.\n  Scales out.\nports:\n  - 80\n  - 443\n---\nname: worker\n",
+		"app.toml":   "title = \"Example\"\n\n[owner]\nname = \"Ada\"\nactive = true\n",
+		"lines.json": "{\"msg\": \"first\"}\n{\"msg\": \"second\"}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		opts     StructuredOptions
+		expected string
+	}{
+		{
+			name:     "flattened json",
+			file:     "app.json",
+			opts:     StructuredOptions{Mode: "flatten"},
+			expected: "env[\"LOG.LEVEL\"] = debug\nenv.empty = []\nname = api\nnote = null\nreplicas = 3\ntags[0] = a\ntags[1] = b\ntags[2] = c",
+		},
+		{
+			name:     "array limit",
+			file:     "app.json",
+			opts:     StructuredOptions{Mode: "flatten", MaxArrayItems: 1},
+			expected: "env[\"LOG.LEVEL\"] = debug\nenv.empty = []\nname = api\nnote = null\nreplicas = 3\ntags[0] = a\ntags = ... (2 more items)",
+		},
+		{
+			name:     "strings only",
+			file:     "app.json",
+			opts:     StructuredOptions{Mode: "flatten", StringsOnly: true},
+			expected: "debug\napi\na\nb\nc",
+		},
+		{
+			name:     "pretty json",
+			file:     "lines.json",
+			opts:     StructuredOptions{Mode: "pretty"},
+			expected: "{\n  \"msg\": \"first\"\n}\n{\n  \"msg\": \"second\"\n}",
+		},
+		{
+			name:     "yaml documents",
+			file:     "app.yaml",
+			opts:     StructuredOptions{Mode: "flatten"},
+			expected: "description = Serves requests.\\nScales out.\\n\nname = api\nports[0] = 80\nports[1] = 443\n\nname = worker",
+		},
+		{
+			name:     "toml",
+			file:     "app.toml",
+			opts:     StructuredOptions{Mode: "flatten"},
+			expected: "owner.active = true\nowner.name = Ada\ntitle = Example",
+		},
+		{
+			name:     "disabled",
+			file:     "app.toml",
+			expected: files["app.toml"],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := NewRegistry(Options{Structured: test.opts}).ExtractFile(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractTabular(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"people.csv": "name,notes\nAda,\"likes, commas\"\nBob,\"two\nlines\"\n",
+		"people.tsv": "name\tage\nAda\t36\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		opts     TabularOptions
+		header   string
+		parts    []string
+		expected string
+	}{
+		{
+			name:     "table",
+			file:     "people.csv",
+			header:   "name,notes\n",
+			parts:    []string{"Ada,\"likes, commas\"\n", "Bob,\"two\nlines\"\n"},
+			expected: "name,notes\nAda,\"likes, commas\"\nBob,\"two\nlines\"\n",
+		},
+		{
+			name:     "records",
+			file:     "people.csv",
+			opts:     TabularOptions{Records: true},
+			parts:    []string{"name: Ada\nnotes: likes, commas\n\n", "name: Bob\nnotes: two\nlines\n\n"},
+			expected: "name: Ada\nnotes: likes, commas\n\nname: Bob\nnotes: two\nlines\n\n",
+		},
+		{
+			name:     "tsv",
+			file:     "people.tsv",
+			header:   "name\tage\n",
+			parts:    []string{"Ada\t36\n"},
+			expected: "name\tage\nAda\t36\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := NewRegistry(Options{Tabular: test.opts}).ExtractFile(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+			if result.Header != test.header {
+				t.Errorf("Unexpected header, expected %q, got %q", test.header, result.Header)
+			}
+			if strings.Join(result.Parts, "|") != strings.Join(test.parts, "|") {
+				t.Errorf("Unexpected parts, expected %q, got %q", test.parts, result.Parts)
+			}
+		})
+	}
+}
+
+func TestExtractSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"meeting.srt": "1\r\n00:00:01,000 --> 00:00:02,500\r\n<i>Welcome</i> everyone,\r\n\r\n" +
+			"2\r\n00:00:02,600 --> 00:00:04,000\r\nlet's begin.\r\n\r\n" +
+			"3\r\n00:01:10,000 --> 00:01:12,000\r\n{\\an8}First item &amp; second.\r\n",
+		"call.vtt": "WEBVTT\n\nNOTE recorded call\n\n" +
+			"intro\n00:05.000 --> 00:07.000 align:start\n<v Ada>Hello Bob.</v>\n\n" +
+			"00:07.100 --> 00:08.000\n<v Bob>Hi Ada,\nhow are you?</v>\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		opts     SubtitleOptions
+		expected string
+	}{
+		{
+			name:     "srt",
+			file:     "meeting.srt",
+			expected: "Welcome everyone, let's begin.\n\nFirst item & second.",
+		},
+		{
+			name:     "srt with timestamps",
+			file:     "meeting.srt",
+			opts:     SubtitleOptions{TimestampInterval: time.Minute},
+			expected: "[00:00:01]\nWelcome everyone, let's begin.\n\n[00:01:10]\nFirst item & second.",
+		},
+		{
+			name:     "vtt with speakers",
+			file:     "call.vtt",
+			expected: "Ada: Hello Bob.\n\nBob: Hi Ada, how are you?",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := NewRegistry(Options{Subtitle: test.opts}).ExtractFile(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractRTF(t *testing.T) {
+	tests := []struct {
+		name     string
+		rtf      string
+		expected string
+	}{
+		{
+			name: "groups and destinations",
+			rtf: `{\rtf1\ansi\deff0{\fonttbl{\f0 Times;}}{\colortbl;\red0\green0\blue0;}` +
+				`{\info{\title Secret}}{\*\generator Word;}` + "\n" +
+				`\pard\b Bold\b0  text\par Second\tab line\par}`,
+			expected: "Bold text\nSecond\tline",
+		},
+		{
+			name:     "unicode escapes",
+			rtf:      `{\rtf1\ansi\uc1 Caf\u233?, {\uc2 \u8364\'80\'80} and \u-3913?.}`,
+			expected: "Café, € and \uf0b7.",
+		},
+		{
+			name:     "code page",
+			rtf:      `{\rtf1\ansi\ansicpg1251 \'cf\'f0\'e8\'e2\'e5\'f2 \{x\}}`,
+			expected: "Привет {x}",
+		},
+		{
+			name:     "raw bytes",
+			rtf:      "{\\rtf1\\ansi \x93Caf\xe9\x94 \x80}",
+			expected: "“Café” €",
+		},
+		{
+			name:     "raw bytes in code page",
+			rtf:      "{\\rtf1\\ansi\\ansicpg1251 \xcf\xf0\xe8\xe2\xe5\xf2}",
+			expected: "Привет",
+		},
+		{
+			name:     "surrogate pairs",
+			rtf:      `{\rtf1\ansi Smile \u-10179?\u-8704?, \u55357?\u56832? and \u-10179?alone.}`,
+			expected: "Smile 😀, 😀 and \ufffdalone.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFileFromBytes("doc.rtf", []byte(test.rtf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewRegistry(Options{}).Extract(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractLaTeX(t *testing.T) {
+	tests := []struct {
+		name     string
+		tex      string
+		expected string
+	}{
+		{
+			name: "document",
+			tex: "\\documentclass{article}\n\\usepackage{amsmath}\n\\title{Ignored}\n" +
+				"\\begin{document}\n\\maketitle\n% a comment\n" +
+				"\\section{Introduction}\\label{sec:intro}\n" +
+				"This is \\textbf{bold} and \\emph{emphasised} text~\\cite{knuth}, 50\\% done.\n\n" +
+				"\\begin{itemize}\n  \\item First\n  \\item[b)] Second\n\\end{itemize}\n" +
+				"\\end{document}\nTrailing",
+			expected: "Introduction\n\nThis is bold and emphasised text , 50% done.\n\n- First\n- b) Second",
+		},
+		{
+			name: "math",
+			tex: "Euler: $e^{i\\pi} + 1 = 0$ and \\(a_1\\).\n" +
+				"\\begin{equation}\n  \\int_0^1 x\\,dx = \\frac{1}{2} % area\n\\end{equation}\n" +
+				"\\[ x^2 \\]",
+			expected: "Euler: $e^{i\\pi} + 1 = 0$ and \\(a_1\\).\n" +
+				"\\begin{equation}\n  \\int_0^1 x\\,dx = \\frac{1}{2}\n\\end{equation}\n" +
+				"\\[ x^2 \\]",
+		},
+		{
+			name:     "links and verbatim",
+			tex:      "See \\href{https://example.com}{the site}.\n\\begin{verbatim}\n  x := 1\n\\end{verbatim}\n",
+			expected: "See the site.\n  x := 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFileFromBytes("paper.tex", []byte(test.tex))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewRegistry(Options{}).Extract(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractProse(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name: "markdown",
+			file: "post.md",
+			content: "---\ntitle: Hello\n---\n# Getting *started*\n\n<!-- draft\nnote -->\n" +
+				"Read the [guide](https://example.com/guide) and ![logo](logo.png) **now**.\n\n" +
+				"- first `item`\n1. second_item\n\n| Name | Value |\n|------|:-----:|\n| a | b |\n\n" +
+				"[guide]: https://example.com\n",
+			expected: "Getting started\n\nRead the guide and now.\n\nfirst item\nsecond_item\n\nName Value\na b",
+		},
+		{
+			name:     "mdx",
+			file:     "page.mdx",
+			content:  "import Chart from './chart'\n\n## Results\n\n<Chart data={data} />\nSee <https://example.com>.\n",
+			expected: "Results\n\nSee .",
+		},
+		{
+			name: "restructuredtext",
+			file: "index.rst",
+			content: "=====\nTitle\n=====\n\n.. image:: logo.png\n   :alt: Logo\n\n" +
+				"Intro with *emphasis*, ``code`` and `a link <https://example.com>`_.\n\n" +
+				".. note:: Be careful\n   with this.\n\n.. _target: https://example.com\n\n" +
+				"* item one\n* :ref:`section <sec>`\n\nExample::\n\n    x = 1\n",
+			expected: "Title\n\nIntro with emphasis, code and a link.\n\nBe careful\nwith this.\n\nitem one\nsection\n\nExample:\n\nx = 1",
+		},
+		{
+			name:     "other extensions pass through",
+			file:     "notes.txt",
+			content:  "# Not converted",
+			expected: "# Not converted",
+		},
+	}
+
+	opts := Options{Prose: ProseOptions{Extensions: []string{".md", ".mdx", ".rst"}}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFileFromBytes(test.file, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewRegistry(opts).Extract(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestExtractComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name: "go",
+			file: "server.go",
+			content: "// Package server serves requests.\npackage server\n\n" +
+				"// Server handles connections.\ntype Server struct {\n\t// Addr is the listen address.\n\tAddr string\n}\n\n" +
+				"// Start starts s.\nfunc (s *Server) Start() {\n\tx := \"// not a comment\"\n\t_ = x // unused\n}\n",
+			expected: "line 1, package server\nPackage server serves requests.\n\n" +
+				"line 4, type Server\nServer handles connections.\n\n" +
+				"line 6, Server.Addr\nAddr is the listen address.\n\n" +
+				"line 10, func Server.Start\nStart starts s.\n\n" +
+				"line 13\nunused",
+		},
+		{
+			name: "python",
+			file: "tool.py",
+			content: "#!/usr/bin/env python\n\"\"\"Command line tool.\"\"\"\n\n# Default value.\nLIMIT = 10\n\n" +
+				"class Parser:\n    \"\"\"Parses input.\n\n    Lines are stripped.\n    \"\"\"\n\n" +
+				"    def parse(self, s):\n        text = \"\"\"# not a comment\"\"\"\n        return text  # the result\n",
+			expected: "line 2, module\nCommand line tool.\n\n" +
+				"line 4\nDefault value.\n\n" +
+				"line 8, class Parser\nParses input.\n\nLines are stripped.\n\n" +
+				"line 15\nthe result",
+		},
+		{
+			name: "javascript",
+			file: "app.js",
+			content: "/**\n * Adds two numbers.\n * @param {number} a\n */\nexport function add(a, b) {\n" +
+				"  return `${a} // ${b}` /* inline */;\n}\n\n// Loads the app.\n// Called once.\nconst load = () => {};\n",
+			expected: "line 1, function add\nAdds two numbers.\n@param {number} a\n\n" +
+				"line 6\ninline\n\n" +
+				"line 9, const load\nLoads the app.\nCalled once.",
+		},
+		{
+			name:     "shell",
+			file:     "build.sh",
+			content:  "#!/bin/sh\n# Builds the project.\nbuild() {\n  echo \"$# args # here\" # count\n}\n",
+			expected: "line 2, build()\nBuilds the project.\n\nline 4\ncount",
+		},
+		{
+			name:     "sql",
+			file:     "schema.sql",
+			content:  "-- Registered users.\nCREATE TABLE IF NOT EXISTS users (\n  name TEXT -- display name\n);\n",
+			expected: "line 1, table users\nRegistered users.\n\nline 3\ndisplay name",
+		},
+		{
+			name:     "unterminated block comment",
+			file:     "main.c",
+			content:  "int x; /* unterminated",
+			expected: "line 1\nunterminated",
+		},
+		{
+			name:     "unterminated block comment delimiter",
+			file:     "main.c",
+			content:  "x /*",
+			expected: "",
+		},
+		{
+			name: "python multi-line signature",
+			file: "load.py",
+			content: "import os\n\"\"\"Not a docstring.\"\"\"\n\n" +
+				"def load(path,\n         mode):\n    # The file is read once.\n    \"\"\"Loads path.\"\"\"\n    x = 1\n    \"\"\"Not a docstring.\"\"\"\n\n" +
+				"async def run():\n    '''Runs.'''\n",
+			expected: "line 6\nThe file is read once.\n\n" +
+				"line 7, def load\nLoads path.\n\n" +
+				"line 12, def run\nRuns.",
+		},
+		{
+			name:     "unterminated docstring",
+			file:     "mod.py",
+			content:  "\"\"\"a",
+			expected: "line 1, module\na",
+		},
+		{
+			name:     "unterminated string literal",
+			file:     "main.c",
+			content:  "char *s = \"open // not a comment\n// After.\nchar *t = \"/* open",
+			expected: "line 2\nAfter.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFileFromBytes(test.file, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewRegistry(Options{Comments: CommentOptions{Only: true}}).Extract(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name: "go",
+			file: "main.go",
+			content: "// Package main runs.\npackage main\n\n\n/* setup */\nfunc main() {   \n" +
+				"\turl := \"http://example.com\" // the site\n\tr := '\"' /* quote */\n\tx := `a // b\n\n c`\n}\n",
+			expected: "package main\nfunc main() {\n\turl := \"http://example.com\"\n\tr := '\"'\n\tx := `a // b\n\n c`\n}",
+		},
+		{
+			name:     "inline block comment",
+			file:     "calc.c",
+			content:  "int x = a/*first*/+b; /* sum */\n",
+			expected: "int x = a +b;",
+		},
+		{
+			name:     "python keeps docstrings",
+			file:     "lib.py",
+			content:  "#!/usr/bin/env python\n# Helpers.\n\ndef f():\n    \"\"\"Doc.\n\n    More.\"\"\"\n    return \"#\"  # hash\n",
+			expected: "#!/usr/bin/env python\ndef f():\n    \"\"\"Doc.\n\n    More.\"\"\"\n    return \"#\"",
+		},
+		{
+			name:     "sql",
+			file:     "q.sql",
+			content:  "SELECT '--x' -- column\nFROM t;\n",
+			expected: "SELECT '--x'\nFROM t;",
+		},
+		{
+			name:     "unterminated block comment",
+			file:     "main.c",
+			content:  "int x; /* open",
+			expected: "int x;",
+		},
+		{
+			name:     "unterminated block comment delimiter",
+			file:     "main.c",
+			content:  "x /*",
+			expected: "x",
+		},
+		{
+			name:     "unterminated docstring",
+			file:     "mod.py",
+			content:  "def f():  # doc\n    \"\"\"a",
+			expected: "def f():\n    \"\"\"a",
+		},
+		{
+			name:     "unterminated string literal",
+			file:     "main.c",
+			content:  "char *s = \"a /* b",
+			expected: "char *s = \"a /* b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFileFromBytes(test.file, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewRegistry(Options{Comments: CommentOptions{Strip: true}}).Extract(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Text != test.expected {
+				t.Errorf("Unexpected text, expected %q, got %q", test.expected, result.Text)
+			}
+		})
+	}
+}
+
+func TestSplitSource(t *testing.T) {
+	tests := []struct {
+		name           string
+		file           string
+		content        string
+		expectedHeader string
+		expectedParts  []string
+	}{
+		{
+			name: "go",
+			file: "main.go",
+			content: "package main\n\nimport \"fmt\"\n\n// A is a constant.\nconst A = 1\n\n" +
+				"func main() {\n\n\tfmt.Println(A)\n}\n",
+			expectedHeader: "package main\n\nimport \"fmt\"\n\n",
+			expectedParts:  []string{"// A is a constant.\nconst A = 1\n\n", "func main() {\n\n\tfmt.Println(A)\n}\n"},
+		},
+		{
+			name:          "go that does not parse",
+			file:          "broken.go",
+			content:       "package main\n\nfunc (\n",
+			expectedParts: []string{"package main\n\n", "func (\n"},
+		},
+		{
+			name: "python",
+			file: "lib.py",
+			content: "import os\n\n@cache\ndef f():\n    x = 1\n\n    return x\n\n\nclass A:\n    pass\n" +
+				"\n}\n",
+			expectedParts: []string{"import os\n\n", "@cache\ndef f():\n    x = 1\n\n    return x\n\n\n", "class A:\n    pass\n\n}\n"},
+		},
+		{
+			name:    "text",
+			file:    "notes.txt",
+			content: "one\n\ntwo\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFileFromBytes(test.file, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewRegistry(Options{}).Extract(f)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Header != test.expectedHeader {
+				t.Errorf("Unexpected header, expected %q, got %q", test.expectedHeader, result.Header)
+			}
+			if !reflect.DeepEqual(result.Parts, test.expectedParts) {
+				t.Errorf("Unexpected parts, expected %q, got %q", test.expectedParts, result.Parts)
+			}
+			if result.Text != result.Header+strings.Join(result.Parts, "") && result.Parts != nil {
+				t.Errorf("Parts do not add up to the text")
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package extractor
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// goComments returns the comments of a Go source file, attributing doc
+// comments to the package, declaration, field or method they document.
+func goComments(path, src string) ([]commentEntry, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := map[*ast.CommentGroup]string{}
+	if file.Doc != nil {
+		symbols[file.Doc] = "package " + file.Name.Name
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Doc != nil {
+				symbols[decl.Doc] = "func " + goFuncName(decl)
+			}
+		case *ast.GenDecl:
+			goGenDeclSymbols(decl, symbols)
+		}
+	}
+
+	entries := make([]commentEntry, 0, len(file.Comments))
+	for _, cg := range file.Comments {
+		text := strings.TrimRight(cg.Text(), "\n")
+		if text == "" {
+			continue
+		}
+		entries = append(entries, commentEntry{
+			line:   fset.Position(cg.Pos()).Line,
+			symbol: symbols[cg],
+			text:   text,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].line < entries[j].line })
+	return entries, nil
+}
+
+// goFuncName returns the name of a function, qualified by its receiver type
+// for methods.
+func goFuncName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	typ := decl.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// goGenDeclSymbols records the symbols documented by the comments of a
+// type, var, const or import declaration and of its specs and fields.
+func goGenDeclSymbols(decl *ast.GenDecl, symbols map[*ast.CommentGroup]string) {
+	kind := decl.Tok.String()
+	var names []string
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, spec.Name.Name)
+			if spec.Doc != nil {
+				symbols[spec.Doc] = kind + " " + spec.Name.Name
+			}
+			if spec.Comment != nil {
+				symbols[spec.Comment] = kind + " " + spec.Name.Name
+			}
+			goFieldSymbols(spec, symbols)
+		case *ast.ValueSpec:
+			var specNames []string
+			for _, name := range spec.Names {
+				specNames = append(specNames, name.Name)
+			}
+			names = append(names, specNames...)
+			for _, cg := range []*ast.CommentGroup{spec.Doc, spec.Comment} {
+				if cg != nil {
+					symbols[cg] = kind + " " + strings.Join(specNames, ", ")
+				}
+			}
+		}
+	}
+	if decl.Doc != nil && len(names) > 0 {
+		symbols[decl.Doc] = kind + " " + strings.Join(names, ", ")
+	}
+}
+
+// goFieldSymbols records the symbols documented by the comments of the
+// fields of a struct type or the methods of an interface type.
+func goFieldSymbols(spec *ast.TypeSpec, symbols map[*ast.CommentGroup]string) {
+	var fields *ast.FieldList
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		fields = t.Fields
+	case *ast.InterfaceType:
+		fields = t.Methods
+	}
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		name := ""
+		if len(field.Names) > 0 {
+			name = field.Names[0].Name
+		} else if ident, ok := field.Type.(*ast.Ident); ok {
+			name = ident.Name
+		}
+		if name == "" {
+			continue
+		}
+		for _, cg := range []*ast.CommentGroup{field.Doc, field.Comment} {
+			if cg != nil {
+				symbols[cg] = spec.Name.Name + "." + name
+			}
+		}
+	}
+}
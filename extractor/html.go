@@ -0,0 +1,322 @@
+package extractor
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLOptions controls how HTML documents are converted to text.
+type HTMLOptions struct {
+	KeepLinks bool // append link targets to the link text as "text (url)"
+	Raw       bool // keep the HTML source unchanged
+}
+
+// extractHTML returns the readable text of the HTML document f.
+func extractHTML(f *File, opts HTMLOptions) (*Result, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	text, err := HTMLToText(rc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: text}, nil
+}
+
+// HTMLToText converts an HTML document to readable text. Scripts and styles
+// are dropped, block elements start new lines, lists are rendered with
+// bullets or numbers and table rows with cells separated by " | ".
+func HTMLToText(r io.Reader, opts HTMLOptions) (string, error) {
+	if opts.Raw {
+		data, err := ioutil.ReadAll(r)
+		return string(data), err
+	}
+
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	hr := &htmlRenderer{opts: opts}
+	hr.render(doc)
+	return normalizeLines(hr.sb.String()), nil
+}
+
+// htmlSkippedElements lists the elements whose content is never rendered.
+var htmlSkippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Iframe: true, atom.Object: true,
+	atom.Select: true, atom.Button: true,
+}
+
+// htmlParagraphElements lists the elements separated from their surroundings
+// by a blank line.
+var htmlParagraphElements = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Table: true, atom.Figure: true, atom.Hr: true, atom.Dl: true,
+	atom.Address: true, atom.Ul: true, atom.Ol: true,
+}
+
+// htmlLineElements lists the elements that start and end a line.
+var htmlLineElements = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Main: true,
+	atom.Li: true, atom.Dt: true, atom.Dd: true, atom.Tr: true,
+	atom.Form: true, atom.Fieldset: true, atom.Details: true,
+	atom.Summary: true, atom.Figcaption: true, atom.Caption: true,
+	atom.Center: true, atom.Body: true,
+}
+
+// htmlRenderer writes the text of an HTML node tree, collapsing whitespace
+// and deferring line breaks until the next piece of text.
+type htmlRenderer struct {
+	opts     HTMLOptions
+	sb       strings.Builder
+	newlines int    // line breaks to emit before the next text
+	space    bool   // whether a space precedes the next text
+	indent   string // prefix of continuation lines inside list items
+	pre      int    // depth of pre elements
+}
+
+// breakLines requests at least n line breaks before the next text.
+func (r *htmlRenderer) breakLines(n int) {
+	if n > r.newlines {
+		r.newlines = n
+	}
+}
+
+// write appends s after any pending line breaks or space.
+func (r *htmlRenderer) write(s string) {
+	if s == "" {
+		return
+	}
+	if r.sb.Len() > 0 {
+		if r.newlines > 0 {
+			r.sb.WriteString(strings.Repeat("\n", r.newlines))
+			r.sb.WriteString(r.indent)
+		} else if r.space {
+			r.sb.WriteByte(' ')
+		}
+	} else {
+		r.sb.WriteString(r.indent)
+	}
+	r.newlines = 0
+	r.space = false
+	r.sb.WriteString(s)
+}
+
+// text appends the content of a text node.
+func (r *htmlRenderer) text(s string) {
+	if r.pre > 0 {
+		for i, line := range strings.Split(s, "\n") {
+			if i > 0 {
+				r.newlines++
+			}
+			r.write(line)
+		}
+		return
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" {
+			r.space = true
+		}
+		return
+	}
+	if strings.TrimLeftFunc(s, unicode.IsSpace) != s {
+		r.space = true
+	}
+	r.write(strings.Join(fields, " "))
+	if strings.TrimRightFunc(s, unicode.IsSpace) != s {
+		r.space = true
+	}
+}
+
+// render appends the text of n and its descendants.
+func (r *htmlRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.DocumentNode:
+		r.renderChildren(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if htmlSkippedElements[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if r.newlines < 2 {
+			r.newlines++
+		}
+		return
+	case atom.Ul, atom.Ol:
+		r.renderList(n)
+		return
+	case atom.Table:
+		r.renderTable(n)
+		return
+	case atom.A:
+		r.renderLink(n)
+		return
+	case atom.Pre:
+		r.breakLines(2)
+		r.pre++
+		r.renderChildren(n)
+		r.pre--
+		r.breakLines(2)
+		return
+	}
+
+	switch {
+	case htmlParagraphElements[n.DataAtom]:
+		r.breakLines(2)
+		r.renderChildren(n)
+		r.breakLines(2)
+	case htmlLineElements[n.DataAtom]:
+		r.breakLines(1)
+		r.renderChildren(n)
+		r.breakLines(1)
+	default:
+		r.renderChildren(n)
+	}
+}
+
+// renderChildren renders the children of n in order.
+func (r *htmlRenderer) renderChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+// renderList renders the items of a ul or ol element, one per line, with
+// nested content indented under its marker.
+func (r *htmlRenderer) renderList(n *html.Node) {
+	if r.indent == "" {
+		r.breakLines(2)
+	} else {
+		r.breakLines(1)
+	}
+
+	number := 1
+	if start := htmlAttr(n, "start"); start != "" {
+		if v, err := strconv.Atoi(start); err == nil {
+			number = v
+		}
+	}
+
+	outer := r.indent
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			if c.Type == html.ElementNode {
+				r.render(c)
+			}
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		r.breakLines(1)
+		r.write(marker)
+		r.space = false
+		r.indent = outer + strings.Repeat(" ", len(marker))
+		r.renderChildren(c)
+		r.indent = outer
+		r.breakLines(1)
+	}
+
+	if outer == "" {
+		r.breakLines(2)
+	} else {
+		r.breakLines(1)
+	}
+}
+
+// renderTable renders each row of a table on its own line with the cells
+// separated by " | ".
+func (r *htmlRenderer) renderTable(n *html.Node) {
+	r.breakLines(2)
+	for _, row := range htmlTableRows(n) {
+		var cells []string
+		for c := row.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			cell := &htmlRenderer{opts: r.opts}
+			cell.renderChildren(c)
+			cells = append(cells, strings.Join(strings.Fields(cell.sb.String()), " "))
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		r.breakLines(1)
+		r.write(strings.Join(cells, " | "))
+	}
+	r.breakLines(2)
+}
+
+// htmlTableRows returns the rows of a table, looking through thead, tbody
+// and tfoot but not into nested tables.
+func htmlTableRows(n *html.Node) []*html.Node {
+	var rows []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Tr:
+			rows = append(rows, c)
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			rows = append(rows, htmlTableRows(c)...)
+		}
+	}
+	return rows
+}
+
+// renderLink renders the text of an a element, followed by its target when
+// links are kept and the target differs from the text.
+func (r *htmlRenderer) renderLink(n *html.Node) {
+	start := r.sb.Len()
+	r.renderChildren(n)
+	if !r.opts.KeepLinks {
+		return
+	}
+	href := strings.TrimSpace(htmlAttr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	if strings.TrimSpace(r.sb.String()[start:]) == href {
+		return
+	}
+	space := r.space
+	r.space = true
+	r.write("(" + href + ")")
+	r.space = space
+}
+
+// htmlAttr returns the value of the named attribute of n.
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,370 @@
+package extractor
+
+import (
+	"bytes"
+	"strings"
+)
+
+// latexSections lists the sectioning commands whose title is kept on a line
+// of its own.
+var latexSections = map[string]bool{
+	"part": true, "chapter": true, "section": true, "subsection": true,
+	"subsubsection": true, "paragraph": true, "subparagraph": true,
+}
+
+// latexDropped lists the commands removed together with their arguments.
+var latexDropped = map[string]bool{
+	"label": true, "ref": true, "eqref": true, "pageref": true, "autoref": true,
+	"cref": true, "Cref": true, "cite": true, "citep": true, "citet": true,
+	"nocite": true, "includegraphics": true, "vspace": true, "hspace": true,
+	"bibliography": true, "bibliographystyle": true, "usepackage": true,
+	"documentclass": true, "newcommand": true, "renewcommand": true,
+	"providecommand": true, "newenvironment": true, "renewenvironment": true,
+	"setlength": true, "addtolength": true, "setcounter": true,
+	"addtocounter": true, "input": true, "include": true, "index": true,
+	"pagestyle": true, "thispagestyle": true, "hypersetup": true,
+	"graphicspath": true, "color": true, "definecolor": true,
+}
+
+// latexSymbols maps the commands that stand for text to that text.
+var latexSymbols = map[string]string{
+	"LaTeX": "LaTeX", "TeX": "TeX", "ldots": "...", "dots": "...",
+	"textbackslash": `\`, "S": "§", "P": "¶", "copyright": "©",
+	"textendash": "–", "textemdash": "—", "par": "\n\n", "newline": "\n",
+}
+
+// latexMathEnvironments lists the environments copied as LaTeX source.
+var latexMathEnvironments = map[string]bool{
+	"equation": true, "equation*": true, "align": true, "align*": true,
+	"gather": true, "gather*": true, "multline": true, "multline*": true,
+	"eqnarray": true, "eqnarray*": true, "displaymath": true, "math": true,
+	"flalign": true, "flalign*": true, "alignat": true, "alignat*": true,
+}
+
+// latexVerbatimEnvironments lists the environments whose content is copied
+// as is, without their begin and end lines.
+var latexVerbatimEnvironments = map[string]bool{
+	"verbatim": true, "verbatim*": true, "lstlisting": true, "minted": true,
+	"Verbatim": true,
+}
+
+// extractLaTeX returns the text of a LaTeX document.
+func extractLaTeX(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: normalizeLines(latexText(string(data)))}, nil
+}
+
+// latexText converts LaTeX source to plain text. The preamble, comments and
+// formatting commands are removed; section titles and text are kept, and
+// math is kept as LaTeX source.
+func latexText(src string) string {
+	src = stripLaTeXComments(strings.ReplaceAll(src, "\r\n", "\n"))
+	if i := strings.Index(src, `\begin{document}`); i >= 0 {
+		src = src[i+len(`\begin{document}`):]
+	}
+	if i := strings.Index(src, `\end{document}`); i >= 0 {
+		src = src[:i]
+	}
+	var buf bytes.Buffer
+	(&latexConverter{src: src, out: &buf}).convert()
+	return buf.String()
+}
+
+// stripLaTeXComments removes everything from an unescaped % to the end of
+// its line, along with the line break of lines that were only a comment.
+func stripLaTeXComments(src string) string {
+	lines := strings.Split(src, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		i := latexCommentIndex(line)
+		if i < 0 {
+			out = append(out, line)
+			continue
+		}
+		if strings.TrimSpace(line[:i]) == "" {
+			continue
+		}
+		out = append(out, line[:i])
+	}
+	return strings.Join(out, "\n")
+}
+
+// latexCommentIndex returns the index of the first % in line that is not
+// escaped by a backslash, or -1.
+func latexCommentIndex(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '%':
+			return i
+		}
+	}
+	return -1
+}
+
+// latexConverter converts the body of a LaTeX document.
+type latexConverter struct {
+	src string
+	pos int
+	out *bytes.Buffer
+}
+
+// convert writes the text of src[pos:] to out.
+func (c *latexConverter) convert() {
+	for c.pos < len(c.src) {
+		ch := c.src[c.pos]
+		switch ch {
+		case '\\':
+			c.control()
+		case '$':
+			c.inlineMath()
+		case '{', '}':
+			c.pos++
+		case '~':
+			c.out.WriteByte(' ')
+			c.pos++
+		case '&':
+			c.out.WriteByte('\t')
+			c.pos++
+		default:
+			c.out.WriteByte(ch)
+			c.pos++
+		}
+	}
+}
+
+// control handles a control sequence starting at pos.
+func (c *latexConverter) control() {
+	start := c.pos
+	c.pos++
+	if c.pos >= len(c.src) {
+		return
+	}
+
+	next := c.src[c.pos]
+	if !isASCIILetter(next) {
+		c.pos++
+		switch next {
+		case '\\':
+			c.out.WriteByte('\n')
+			c.skipOptional()
+		case '(':
+			c.copyUntil(start, `\)`)
+		case '[':
+			c.copyUntil(start, `\]`)
+		case ' ', '\n':
+			c.out.WriteByte(' ')
+		case ',', ';', ':', '!', '/', '-':
+		default:
+			c.out.WriteByte(next)
+		}
+		return
+	}
+
+	for c.pos < len(c.src) && isASCIILetter(c.src[c.pos]) {
+		c.pos++
+	}
+	name := c.src[start+1 : c.pos]
+	if c.pos < len(c.src) && c.src[c.pos] == '*' {
+		c.pos++
+	}
+
+	if s, ok := latexSymbols[name]; ok {
+		if c.pos < len(c.src) && c.src[c.pos] == '{' && strings.HasPrefix(c.src[c.pos:], "{}") {
+			c.pos += 2
+		}
+		c.out.WriteString(s)
+		return
+	}
+	c.skipSpace()
+
+	switch {
+	case latexSections[name]:
+		c.skipOptional()
+		c.out.WriteString("\n\n" + c.convertArg() + "\n\n")
+	case name == "begin":
+		c.begin(start)
+	case name == "end":
+		c.argument()
+		c.lineBreak()
+	case name == "item":
+		c.lineBreak()
+		c.out.WriteString("- ")
+		if c.pos < len(c.src) && c.src[c.pos] == '[' {
+			c.out.WriteString(c.convertString(c.optional()) + " ")
+			c.skipSpace()
+		}
+	case name == "href":
+		c.argument()
+		c.out.WriteString(c.convertArg())
+	case latexDropped[name]:
+		c.skipArguments()
+	default:
+		// Formatting and unknown commands: drop the command and its options
+		// and keep the content of its arguments.
+		c.skipOptional()
+	}
+}
+
+// begin handles a \begin{env} whose backslash is at start.
+func (c *latexConverter) begin(start int) {
+	env := c.argument()
+	end := `\end{` + env + `}`
+	switch {
+	case latexMathEnvironments[env]:
+		c.copyUntil(start, end)
+	case latexVerbatimEnvironments[env]:
+		c.skipArguments()
+		i := strings.Index(c.src[c.pos:], end)
+		if i < 0 {
+			i = len(c.src) - c.pos
+		}
+		c.lineBreak()
+		c.out.WriteString(strings.Trim(c.src[c.pos:c.pos+i], "\n"))
+		c.lineBreak()
+		c.pos += i + len(end)
+		if c.pos > len(c.src) {
+			c.pos = len(c.src)
+		}
+	default:
+		c.skipOptional()
+		if env == "tabular" || env == "tabular*" || env == "array" {
+			c.skipArguments()
+		}
+		c.lineBreak()
+	}
+}
+
+// lineBreak ends the current output line unless it is already empty, in
+// which case its indentation is removed.
+func (c *latexConverter) lineBreak() {
+	b := c.out.Bytes()
+	trimmed := bytes.TrimRight(b, " \t")
+	if len(trimmed) == 0 || trimmed[len(trimmed)-1] == '\n' {
+		c.out.Truncate(len(trimmed))
+		return
+	}
+	c.out.WriteByte('\n')
+}
+
+// inlineMath copies a $...$ or $$...$$ formula starting at pos.
+func (c *latexConverter) inlineMath() {
+	if strings.HasPrefix(c.src[c.pos:], "$$") {
+		c.copyUntil(c.pos, "$$")
+		return
+	}
+	start := c.pos
+	for c.pos++; c.pos < len(c.src); c.pos++ {
+		if c.src[c.pos] == '\\' {
+			c.pos++
+			continue
+		}
+		if c.src[c.pos] == '$' {
+			c.pos++
+			break
+		}
+	}
+	if c.pos > len(c.src) {
+		c.pos = len(c.src)
+	}
+	c.out.WriteString(c.src[start:c.pos])
+}
+
+// copyUntil copies the source from start through the next occurrence of
+// delim after pos, or through the end of the document.
+func (c *latexConverter) copyUntil(start int, delim string) {
+	from := c.pos
+	if strings.HasPrefix(c.src[start:], delim) {
+		from = start + len(delim)
+	}
+	i := strings.Index(c.src[from:], delim)
+	if i < 0 {
+		c.pos = len(c.src)
+	} else {
+		c.pos = from + i + len(delim)
+	}
+	c.out.WriteString(c.src[start:c.pos])
+}
+
+// skipSpace skips the spaces after a control word.
+func (c *latexConverter) skipSpace() {
+	for c.pos < len(c.src) && (c.src[c.pos] == ' ' || c.src[c.pos] == '\t') {
+		c.pos++
+	}
+}
+
+// skipOptional skips any [...] options at pos.
+func (c *latexConverter) skipOptional() {
+	for c.pos < len(c.src) && c.src[c.pos] == '[' {
+		c.optional()
+	}
+}
+
+// skipArguments skips all the [...] and {...} arguments at pos.
+func (c *latexConverter) skipArguments() {
+	for c.pos < len(c.src) && (c.src[c.pos] == '[' || c.src[c.pos] == '{') {
+		if c.src[c.pos] == '[' {
+			c.optional()
+		} else {
+			c.argument()
+		}
+	}
+}
+
+// optional returns the source of the [...] option at pos and moves past it.
+func (c *latexConverter) optional() string {
+	return c.delimited('[', ']')
+}
+
+// argument returns the source of the {...} argument at pos and moves past
+// it. A command without braces takes the next character as its argument.
+func (c *latexConverter) argument() string {
+	if c.pos < len(c.src) && c.src[c.pos] != '{' {
+		c.pos++
+		return c.src[c.pos-1 : c.pos]
+	}
+	return c.delimited('{', '}')
+}
+
+// convertArg returns the text of the {...} argument at pos.
+func (c *latexConverter) convertArg() string {
+	return c.convertString(c.argument())
+}
+
+// convertString returns the text of a fragment of LaTeX source.
+func (c *latexConverter) convertString(src string) string {
+	var buf bytes.Buffer
+	(&latexConverter{src: src, out: &buf}).convert()
+	return strings.TrimSpace(buf.String())
+}
+
+// delimited returns the source between the open delimiter at pos and its
+// matching close delimiter, and moves past it.
+func (c *latexConverter) delimited(open, close byte) string {
+	if c.pos >= len(c.src) || c.src[c.pos] != open {
+		return ""
+	}
+	depth := 0
+	for i := c.pos; i < len(c.src); i++ {
+		switch c.src[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				s := c.src[c.pos+1 : i]
+				c.pos = i + 1
+				return s
+			}
+		}
+	}
+	s := c.src[c.pos+1:]
+	c.pos = len(c.src)
+	return s
+}
@@ -0,0 +1,127 @@
+package extractor
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// NotebookOptions controls how Jupyter notebooks are converted to text.
+type NotebookOptions struct {
+	Outputs bool // include the text outputs of code cells
+}
+
+// notebook is the subset of the Jupyter notebook format (nbformat 3 and 4)
+// needed to extract its cells.
+type notebook struct {
+	Cells      []notebookCell `json:"cells"`
+	Worksheets []struct {
+		Cells []notebookCell `json:"cells"`
+	} `json:"worksheets"`
+}
+
+// notebookCell is one notebook cell. Version 3 notebooks keep the source of
+// code cells in Input instead of Source.
+type notebookCell struct {
+	CellType string           `json:"cell_type"`
+	Source   multilineString  `json:"source"`
+	Input    multilineString  `json:"input"`
+	Outputs  []notebookOutput `json:"outputs"`
+}
+
+// notebookOutput is one output of a code cell.
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Text       multilineString            `json:"text"`
+	Data       map[string]multilineString `json:"data"`
+	EName      string                     `json:"ename"`
+	EValue     string                     `json:"evalue"`
+}
+
+// multilineString is a notebook string, stored either as one string or as a
+// list of lines.
+type multilineString string
+
+// UnmarshalJSON accepts both representations of a multiline string. Values
+// of other types, such as JSON outputs, decode to the empty string.
+func (m *multilineString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = multilineString(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*m = multilineString(strings.Join(lines, ""))
+		return nil
+	}
+	*m = ""
+	return nil
+}
+
+// extractNotebook returns the cells of a Jupyter notebook in order, each
+// preceded by a "# %% [type]" marker. Text outputs of code cells follow
+// their source when enabled; images and other binary outputs are dropped.
+func extractNotebook(f *File, opts NotebookOptions) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, err
+	}
+
+	cells := nb.Cells
+	for _, ws := range nb.Worksheets {
+		cells = append(cells, ws.Cells...)
+	}
+
+	var parts []string
+	for _, cell := range cells {
+		source := string(cell.Source)
+		if source == "" {
+			source = string(cell.Input)
+		}
+		cellType := cell.CellType
+		if cellType == "heading" {
+			cellType = "markdown"
+		}
+
+		part := "# %% [" + cellType + "]\n" + strings.TrimRight(source, "\n")
+		if opts.Outputs && cell.CellType == "code" {
+			if out := notebookOutputText(cell.Outputs); out != "" {
+				part += "\n# Output:\n" + out
+			}
+		}
+		parts = append(parts, part)
+	}
+
+	return &Result{
+		Text:     strings.Join(parts, "\n\n"),
+		Metadata: map[string]string{"cells": strconv.Itoa(len(cells))},
+	}, nil
+}
+
+// notebookOutputText returns the text of the outputs of a code cell.
+func notebookOutputText(outputs []notebookOutput) string {
+	var texts []string
+	for _, out := range outputs {
+		var text string
+		switch out.OutputType {
+		case "stream":
+			text = string(out.Text)
+		case "execute_result", "display_data", "pyout":
+			text = string(out.Data["text/plain"])
+			if text == "" {
+				text = string(out.Text)
+			}
+		case "error", "pyerr":
+			text = out.EName + ": " + out.EValue
+		}
+		if text = strings.TrimRight(text, "\n"); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
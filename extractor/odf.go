@@ -0,0 +1,131 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// extractOpenDocument returns the text of an OpenDocument text (.odt),
+// spreadsheet (.ods) or presentation (.odp) package by parsing its
+// content.xml. Table cells are separated by tabs and rows, paragraphs and
+// slides by newlines.
+func extractOpenDocument(f *File) (*Result, error) {
+	zr, err := openZip(f)
+	if err != nil {
+		return nil, err
+	}
+	content, err := readZipEntry(zr, "content.xml")
+	if err != nil {
+		return nil, err
+	}
+	text, err := openDocumentText(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: text}, nil
+}
+
+// openDocumentText converts an OpenDocument content.xml stream to text.
+func openDocumentText(r io.Reader) (string, error) {
+	d := xml.NewDecoder(r)
+	var b textBuilder
+	inParagraph := 0 // depth of text:p and text:h elements
+	inCell := 0      // depth of table:table-cell elements
+	skip := 0        // depth of elements whose text is not part of the document
+	cellStarted := false
+	cellParagraphs := 0 // paragraphs seen in the current cell
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "tracked-changes", "note-citation", "sequence-decls":
+				skip++
+			case "p", "h":
+				if inCell > 0 && inParagraph == 0 {
+					if cellParagraphs > 0 {
+						b.WriteString(" ")
+					}
+					cellParagraphs++
+				}
+				inParagraph++
+			case "s":
+				n, _ := strconv.Atoi(attr(t, "c"))
+				if n < 1 {
+					n = 1
+				}
+				b.WriteString(strings.Repeat(" ", n))
+			case "tab":
+				b.WriteString("\t")
+			case "line-break":
+				b.WriteString("\n")
+			case "table-cell", "covered-table-cell":
+				if cellStarted {
+					b.WriteString("\t")
+				}
+				cellStarted = true
+				cellParagraphs = 0
+				inCell++
+			case "table-row", "page":
+				b.Newline()
+				cellStarted = false
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				inParagraph--
+				if inCell == 0 && inParagraph == 0 {
+					b.Newline()
+				}
+			case "table-cell", "covered-table-cell":
+				inCell--
+			case "table-row", "page", "table":
+				b.Newline()
+				cellStarted = false
+			}
+		case xml.CharData:
+			if skip == 0 && inParagraph > 0 {
+				b.WriteString(collapseSpace(string(t)))
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+// collapseSpace replaces every run of XML whitespace in s with one space.
+func collapseSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		sb.WriteRune(r)
+		space = false
+	}
+	return sb.String()
+}
@@ -0,0 +1,223 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ProseOptions selects the markup files converted to plain prose.
+type ProseOptions struct {
+	Extensions []string // extensions of Markdown and reStructuredText files to convert
+}
+
+var (
+	proseHTMLComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	proseHTMLTag       = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+	proseRule          = regexp.MustCompile(`^([-*_=~^"'#+.:])( ?([-*_=~^"'#+.:]))*$`)
+	proseListMarker    = regexp.MustCompile(`^([-*+•]|\d+[.)]|#\.)\s+`)
+	proseTableRule     = regexp.MustCompile(`^[|+]?\s*:?-{2,}:?\s*([|+]\s*:?-{2,}:?\s*)*[|+]?$|^\+[-=+]+\+$`)
+	markdownHeading    = regexp.MustCompile(`^#{1,6}\s+(.*?)(\s+#+)?$`)
+	markdownFence      = regexp.MustCompile("^(```+|~~~+)")
+	markdownImage      = regexp.MustCompile(`!\[[^\]]*\](\([^)]*\)|\[[^\]]*\])`)
+	markdownLink       = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	markdownAutolink   = regexp.MustCompile(`<(https?|ftp|mailto):[^>]*>`)
+	markdownDefinition = regexp.MustCompile(`^\[[^\]]+\]:\s+\S`)
+	markdownEmphasis   = regexp.MustCompile(`(\*\*|__|~~)(\S(?:.*?\S)?)(\*\*|__|~~)`)
+	markdownStar       = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	markdownUnderscore = regexp.MustCompile(`(^|[^\w])_(\S(?:[^_]*?\S)?)_($|[^\w])`)
+	markdownCode       = regexp.MustCompile("`+([^`]+)`+")
+	markdownEscape     = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!|<>~])")
+	mdxStatement       = regexp.MustCompile(`^(import|export)\s`)
+	rstDirective       = regexp.MustCompile(`^\.\.\s+(\|[^|]+\|\s+)?([\w:-]+)::(.*)$`)
+	rstOption          = regexp.MustCompile(`^:[\w -]+:`)
+	rstRole            = regexp.MustCompile("(:[\\w:-]+:)?`([^`<]*?)\\s*(<[^>]*>)?`(__?)?")
+	rstLiteral         = regexp.MustCompile("``([^`]+)``")
+	rstEmphasis        = regexp.MustCompile(`\*\*?(\S(?:[^*]*?\S)?)\*\*?`)
+	rstReference       = regexp.MustCompile(`(^|[^\w])([A-Za-z0-9][\w-]*?)__?($|[\s.,;:!?)])`)
+)
+
+// rstAdmonitions lists the directives whose argument is text.
+var rstAdmonitions = map[string]bool{
+	"note": true, "warning": true, "tip": true, "important": true,
+	"caution": true, "danger": true, "error": true, "hint": true,
+	"attention": true, "admonition": true, "topic": true, "sidebar": true,
+	"rubric": true, "seealso": true,
+}
+
+// rstDroppedDirectives lists the directives removed together with their
+// content.
+var rstDroppedDirectives = map[string]bool{
+	"image": true, "figure": true, "raw": true, "include": true,
+	"toctree": true, "contents": true, "meta": true, "replace": true,
+}
+
+// extractProse returns a Markdown or reStructuredText document as plain
+// prose, according to its extension.
+func extractProse(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	src := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if f.Ext == ".rst" || f.Ext == ".rest" {
+		return &Result{Text: normalizeLines(rstProse(src))}, nil
+	}
+	return &Result{Text: normalizeLines(markdownProse(src))}, nil
+}
+
+// markdownProse strips the syntax of a Markdown or MDX document: front
+// matter, HTML comments and tags, link targets, images, table pipes and
+// emphasis markers are removed, while the text of headings, list items and
+// paragraphs is kept. Fenced code is kept without its fences.
+func markdownProse(src string) string {
+	src = stripFrontMatter(src)
+	src = proseHTMLComment.ReplaceAllString(src, "")
+
+	var out []string
+	fence := ""
+	for _, line := range strings.Split(src, "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if m := markdownFence.FindString(line); m != "" {
+			fence = m
+			continue
+		}
+		for strings.HasPrefix(line, ">") {
+			line = strings.TrimSpace(line[1:])
+		}
+		switch {
+		case line == "":
+		case proseRule.MatchString(line) && len(line) >= 3,
+			proseTableRule.MatchString(line),
+			markdownDefinition.MatchString(line),
+			mdxStatement.MatchString(line):
+			continue
+		case markdownHeading.MatchString(line):
+			line = markdownHeading.FindStringSubmatch(line)[1]
+		case strings.HasPrefix(line, "|"):
+			line = tableRowText(line)
+		default:
+			line = proseListMarker.ReplaceAllString(line, "")
+			line = strings.TrimPrefix(strings.TrimPrefix(line, "[ ] "), "[x] ")
+		}
+		out = append(out, markdownInline(line))
+	}
+	return strings.Join(out, "\n")
+}
+
+// markdownInline strips the inline syntax of a line of Markdown.
+func markdownInline(line string) string {
+	line = markdownImage.ReplaceAllString(line, "")
+	line = markdownLink.ReplaceAllString(line, "$1")
+	line = markdownAutolink.ReplaceAllString(line, "")
+	line = proseHTMLTag.ReplaceAllString(line, "")
+	line = markdownCode.ReplaceAllString(line, "$1")
+	line = markdownEmphasis.ReplaceAllString(line, "$2")
+	line = markdownStar.ReplaceAllString(line, "$1")
+	line = markdownUnderscore.ReplaceAllString(line, "$1$2$3")
+	line = markdownEscape.ReplaceAllString(line, "$1")
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// stripFrontMatter removes a YAML (---) or TOML (+++) front matter block
+// from the start of src.
+func stripFrontMatter(src string) string {
+	for _, delim := range []string{"---", "+++"} {
+		if !strings.HasPrefix(src, delim+"\n") {
+			continue
+		}
+		rest := src[len(delim)+1:]
+		if i := strings.Index(rest, "\n"+delim); i >= 0 {
+			rest = rest[i+len(delim)+1:]
+			return strings.TrimPrefix(rest, "\n")
+		}
+	}
+	return src
+}
+
+// tableRowText returns the cells of a "| a | b |" table row separated by
+// spaces.
+func tableRowText(line string) string {
+	cells := strings.Split(strings.Trim(line, "|"), "|")
+	texts := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			texts = append(texts, cell)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// rstProse strips the syntax of a reStructuredText document: section
+// adornments, comments, hyperlink targets, images, table borders, roles and
+// inline markup are removed, while the text of titles, list items,
+// paragraphs and admonitions is kept.
+func rstProse(src string) string {
+	var out []string
+	skipIndented := false // inside a comment or dropped directive
+	inDirective := false  // inside a directive whose content is kept
+	for _, raw := range strings.Split(src, "\n") {
+		indented := raw != "" && (raw[0] == ' ' || raw[0] == '\t')
+		line := strings.TrimSpace(raw)
+		if skipIndented || inDirective {
+			if indented || line == "" {
+				if skipIndented || rstOption.MatchString(line) {
+					continue
+				}
+			} else {
+				skipIndented, inDirective = false, false
+			}
+		}
+
+		switch {
+		case line == "":
+		case rstDirective.MatchString(line):
+			m := rstDirective.FindStringSubmatch(line)
+			if m[1] != "" || rstDroppedDirectives[m[2]] {
+				skipIndented = true
+				continue
+			}
+			inDirective = true
+			if !rstAdmonitions[m[2]] {
+				continue
+			}
+			line = strings.TrimSpace(m[3])
+		case line == ".." || strings.HasPrefix(line, ".. "):
+			// Comments, hyperlink targets and footnotes.
+			skipIndented = true
+			continue
+		case line == "::":
+			continue
+		case proseRule.MatchString(line) && len(line) >= 3,
+			proseTableRule.MatchString(line),
+			strings.HasPrefix(line, "+-") || strings.HasPrefix(line, "+="):
+			continue
+		case strings.HasPrefix(line, "|") && strings.HasSuffix(line, "|") && len(line) > 1:
+			line = tableRowText(line)
+		default:
+			line = proseListMarker.ReplaceAllString(line, "")
+			if strings.HasSuffix(line, "::") {
+				line = strings.TrimSuffix(line, ":")
+			}
+		}
+		out = append(out, rstInline(line))
+	}
+	return strings.Join(out, "\n")
+}
+
+// rstInline strips the inline markup of a line of reStructuredText.
+func rstInline(line string) string {
+	line = rstLiteral.ReplaceAllString(line, "$1")
+	line = rstRole.ReplaceAllString(line, "$2")
+	line = rstEmphasis.ReplaceAllString(line, "$1")
+	line = rstReference.ReplaceAllString(line, "$1$2$3")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,212 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Options configures the built-in extractors.
+type Options struct {
+	HTML       HTMLOptions
+	Notebook   NotebookOptions
+	Structured StructuredOptions
+	Tabular    TabularOptions
+	Subtitle   SubtitleOptions
+	Prose      ProseOptions
+	Comments   CommentOptions
+	Commands   []Command // external commands, overriding the native extractors
+}
+
+var (
+	registeredMu sync.Mutex
+	registered   []Extractor
+)
+
+// Register adds e to the extractors included in every registry created
+// afterwards by NewRegistry. Registered extractors take precedence over the
+// built-in ones, and later registrations over earlier ones.
+func Register(e Extractor) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered = append(registered, e)
+}
+
+// Registry selects the extractor to use for each file.
+type Registry struct {
+	extractors []Extractor
+}
+
+// NewRegistry returns a registry holding the built-in extractors configured
+// by opts followed by the extractors added with Register.
+func NewRegistry(opts Options) *Registry {
+	r := &Registry{}
+	for _, e := range builtinExtractors(opts) {
+		r.Add(e)
+	}
+
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for _, e := range registered {
+		r.Add(e)
+	}
+	return r
+}
+
+// Add adds e to the registry, taking precedence over the extractors added
+// before it.
+func (r *Registry) Add(e Extractor) {
+	r.extractors = append(r.extractors, e)
+}
+
+// Lookup returns the extractor for f, or nil if none matches.
+func (r *Registry) Lookup(f *File) Extractor {
+	for i := len(r.extractors) - 1; i >= 0; i-- {
+		if r.extractors[i].Match(f) {
+			return r.extractors[i]
+		}
+	}
+	return nil
+}
+
+// ExtractError reports that a built-in extractor could not extract the text
+// of a file, such as a malformed document.
+type ExtractError struct {
+	Extractor string // name of the extractor
+	Path      string // path of the file
+	Err       error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("%s extractor: %s: %v", e.Extractor, e.Path, e.Err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// Extract returns the text of f using the extractor registered for it. The
+// name of that extractor is recorded in the "extractor" metadata key. The
+// failures of built-in extractors are returned as an *ExtractError; those of
+// external commands, which choose what to do on error, are not.
+func (r *Registry) Extract(f *File) (*Result, error) {
+	e := r.Lookup(f)
+	if e == nil {
+		return nil, fmt.Errorf("no extractor for %s", f.Path)
+	}
+	result, err := e.Extract(f)
+	if err != nil {
+		if _, ok := e.(*commandExtractor); ok {
+			return nil, fmt.Errorf("%s extractor: %s: %w", e.Name(), f.Path, err)
+		}
+		return nil, &ExtractError{Extractor: e.Name(), Path: f.Path, Err: err}
+	}
+	if result.Metadata == nil {
+		result.Metadata = map[string]string{}
+	}
+	result.Metadata["extractor"] = e.Name()
+	return result, nil
+}
+
+// Stream returns a reader of the text of f when the extractor registered
+// for it implements Streamer and can stream f, or nil when f has to be
+// extracted with Extract. A new reader is returned on every call.
+func (r *Registry) Stream(f *File) (io.ReadCloser, error) {
+	s, ok := r.Lookup(f).(Streamer)
+	if !ok {
+		return nil, nil
+	}
+	return s.Stream(f)
+}
+
+// ExtractFile returns the text of the file at path on disk.
+func (r *Registry) ExtractFile(path string) (*Result, error) {
+	f, err := NewFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Extract(f)
+}
+
+// builtinExtractors returns the extractors shipped with the package, the
+// plain text fallback first so that every other extractor overrides it, and
+// the configured external commands last, in reverse so that the first
+// matching command wins.
+func builtinExtractors(opts Options) []Extractor {
+	extractors := []Extractor{
+		textExtractor{New("text", func(*File) bool { return true }, extractText)},
+		New("opendocument", MatchAny(
+			MatchExtension(".odt", ".ods", ".odp"),
+			MatchMagic(30, []byte("mimetypeapplication/vnd.oasis.opendocument.")),
+		), extractOpenDocument),
+		New("epub", MatchAny(
+			MatchExtension(".epub"),
+			MatchMagic(30, []byte("mimetypeapplication/epub+zip")),
+		), extractEPUB),
+		New("html", MatchAny(
+			MatchExtension(".html", ".htm", ".xhtml"),
+			func(f *File) bool { return f.Ext == "" && f.MIME == "text/html" },
+		), func(f *File) (*Result, error) {
+			return extractHTML(f, opts.HTML)
+		}),
+		New("notebook", MatchExtension(".ipynb"), func(f *File) (*Result, error) {
+			return extractNotebook(f, opts.Notebook)
+		}),
+		New("email", MatchExtension(".eml"), extractEmail),
+		New("mbox", MatchExtension(".mbox"), extractMbox),
+		New("tabular", MatchExtension(".csv", ".tsv", ".tab"), func(f *File) (*Result, error) {
+			return extractTabular(f, opts.Tabular)
+		}),
+		New("subtitle", MatchExtension(".srt", ".vtt"), func(f *File) (*Result, error) {
+			return extractSubtitles(f, opts.Subtitle)
+		}),
+		New("rtf", MatchAny(MatchExtension(".rtf"), MatchMagic(0, []byte(`{\rtf`))), extractRTF),
+		New("latex", MatchExtension(".tex", ".latex", ".ltx"), extractLaTeX),
+	}
+	if opts.Structured.Mode != "" {
+		extractors = append(extractors, New("structured", MatchExtension(".json", ".jsonl", ".ndjson", ".yaml", ".yml", ".toml"),
+			func(f *File) (*Result, error) {
+				return extractStructured(f, opts.Structured)
+			}))
+	}
+	if len(opts.Prose.Extensions) > 0 {
+		extractors = append(extractors, New("prose", MatchExtension(opts.Prose.Extensions...), extractProse))
+	}
+	if opts.Comments.Strip {
+		extractors = append(extractors, New("strip-comments", MatchExtension(commentExtensions()...), extractStripped))
+	}
+	if opts.Comments.Only {
+		extractors = append(extractors, New("comments", MatchExtension(commentExtensions()...), extractComments))
+	}
+	for i := len(opts.Commands) - 1; i >= 0; i-- {
+		extractors = append(extractors, NewCommand(opts.Commands[i]))
+	}
+	return extractors
+}
+
+// textExtractor is the plain text fallback. It streams the files that it
+// does not split into parts.
+type textExtractor struct {
+	Extractor
+}
+
+func (textExtractor) Stream(f *File) (io.ReadCloser, error) {
+	if _, ok := commentSyntaxes[f.Ext]; ok {
+		return nil, nil
+	}
+	return f.Open()
+}
+
+// extractText returns the content of f unchanged. Source files are split at
+// declaration boundaries for chunking.
+func extractText(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Text: string(data)}
+	if _, ok := commentSyntaxes[f.Ext]; ok {
+		result.Header, result.Parts = splitSource(f.Ext, result.Text)
+	}
+	return result, nil
+}
@@ -0,0 +1,218 @@
+package extractor
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfSkippedDestinations lists the RTF destinations whose content is not
+// document text.
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "themedata": true, "colorschememapping": true,
+	"latentstyles": true, "datastore": true, "xmlnstbl": true, "listtable": true,
+	"listoverridetable": true, "rsidtbl": true, "generator": true, "filetbl": true,
+	"revtbl": true, "pgdsctbl": true, "fldinst": true, "header": true,
+	"footer": true, "headerl": true, "headerr": true, "headerf": true,
+	"footerl": true, "footerr": true, "footerf": true, "bkmkstart": true,
+	"bkmkend": true, "pn": true, "listtext": true,
+}
+
+// rtfSymbols maps the control words that stand for characters to their text.
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n\n", "page": "\n\n", "row": "\n",
+	"tab": "\t", "cell": "\t", "emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+	"emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// rtfCodePages maps the \ansicpg values to their encodings.
+var rtfCodePages = map[int]encoding.Encoding{
+	437: charmap.CodePage437, 850: charmap.CodePage850, 852: charmap.CodePage852,
+	866: charmap.CodePage866, 874: charmap.Windows874, 1250: charmap.Windows1250,
+	1251: charmap.Windows1251, 1252: charmap.Windows1252, 1253: charmap.Windows1253,
+	1254: charmap.Windows1254, 1255: charmap.Windows1255, 1256: charmap.Windows1256,
+	1257: charmap.Windows1257, 1258: charmap.Windows1258, 10000: charmap.Macintosh,
+}
+
+// rtfGroup is the parser state saved at each opening brace.
+type rtfGroup struct {
+	skip bool // the group is an ignored destination
+	uc   int  // characters to skip after a \u escape
+}
+
+// extractRTF returns the text of an RTF document.
+func extractRTF(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Text: normalizeLines(rtfText(string(data)))}, nil
+}
+
+// rtfText converts RTF source to plain text. Groups, ignored destinations,
+// \uN Unicode escapes, including UTF-16 surrogate pairs, and \'hh and raw
+// 8-bit bytes in the document code page are handled.
+func rtfText(src string) string {
+	var sb strings.Builder
+	state := rtfGroup{uc: 1}
+	var stack []rtfGroup
+	codePage := encoding.Encoding(charmap.Windows1252)
+	pendingSkip := 0 // fallback characters still to skip after \uN
+	var high rune    // high surrogate of a \uN pair waiting for its low one
+
+	// unpaired replaces a high surrogate left without its low one.
+	unpaired := func() {
+		if high != 0 {
+			sb.WriteRune(utf8.RuneError)
+			high = 0
+		}
+	}
+	emit := func(s string) {
+		if !state.skip {
+			unpaired()
+			sb.WriteString(s)
+		}
+	}
+	emitByte := func(b byte) {
+		if decoded, err := codePage.NewDecoder().Bytes([]byte{b}); err == nil {
+			emit(string(decoded))
+		}
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch c {
+		case '{':
+			stack = append(stack, state)
+			pendingSkip = 0
+			continue
+		case '}':
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+			pendingSkip = 0
+			continue
+		case '\r', '\n':
+			continue
+		case '\\':
+		default:
+			if pendingSkip > 0 {
+				pendingSkip--
+				continue
+			}
+			if c >= 0x80 {
+				emitByte(c)
+			} else {
+				emit(string(c))
+			}
+			continue
+		}
+
+		// Control symbol or control word.
+		if i+1 >= len(src) {
+			break
+		}
+		next := src[i+1]
+		if !isASCIILetter(next) {
+			i++
+			switch next {
+			case '\'':
+				if i+2 < len(src) {
+					b, err := strconv.ParseUint(src[i+1:i+3], 16, 8)
+					i += 2
+					if err != nil {
+						continue
+					}
+					if pendingSkip > 0 {
+						pendingSkip--
+						continue
+					}
+					emitByte(byte(b))
+				}
+			case '*':
+				state.skip = true
+			case '~':
+				emit(" ")
+			case '_':
+				emit("-")
+			case '\\', '{', '}':
+				emit(string(next))
+			case '\n', '\r':
+				emit("\n")
+			}
+			continue
+		}
+
+		j := i + 1
+		for j < len(src) && isASCIILetter(src[j]) {
+			j++
+		}
+		word := src[i+1 : j]
+		k := j
+		if k < len(src) && (src[k] == '-' || isASCIIDigit(src[k])) {
+			k++
+			for k < len(src) && isASCIIDigit(src[k]) {
+				k++
+			}
+		}
+		param, hasParam := 0, k > j
+		if hasParam {
+			param, _ = strconv.Atoi(src[j:k])
+		}
+		if k < len(src) && src[k] == ' ' {
+			k++
+		}
+		i = k - 1
+
+		switch {
+		case word == "u" && hasParam:
+			if param < 0 {
+				param += 65536
+			}
+			switch r := rune(param); {
+			case r >= 0xD800 && r < 0xDC00 && !state.skip:
+				unpaired()
+				high = r
+			case r >= 0xDC00 && r < 0xE000 && high != 0:
+				pair := utf16.DecodeRune(high, r)
+				high = 0
+				emit(string(pair))
+			default:
+				emit(string(r))
+			}
+			pendingSkip = state.uc
+		case word == "uc" && hasParam:
+			state.uc = param
+		case word == "ansicpg" && hasParam:
+			if enc, ok := rtfCodePages[param]; ok {
+				codePage = enc
+			}
+		case rtfSkippedDestinations[word]:
+			state.skip = true
+		default:
+			if s, ok := rtfSymbols[word]; ok {
+				emit(s)
+			}
+		}
+	}
+
+	unpaired()
+	return sb.String()
+}
+
+// isASCIILetter reports whether c is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isASCIIDigit reports whether c is an ASCII digit.
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,96 @@
+package extractor
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// splitSource cuts the text of a source file into parts at top-level
+// declaration boundaries, for chunking. Go files are split with go/parser
+// and get their package clause and imports as header; other languages, and
+// Go files that do not parse, are split at blank lines followed by an
+// unindented line.
+func splitSource(ext, text string) (string, []string) {
+	if ext == ".go" {
+		if header, parts, ok := splitGo(text); ok {
+			return header, parts
+		}
+	}
+	return "", splitBlocks(text)
+}
+
+// splitGo splits Go source before each top-level declaration following the
+// imports, including its doc comment. The package clause and imports form
+// the header.
+func splitGo(src string) (string, []string, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return "", nil, false
+	}
+
+	var starts []int
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		pos := decl.Pos()
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Doc != nil {
+				pos = decl.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if decl.Doc != nil {
+				pos = decl.Doc.Pos()
+			}
+		}
+		offset := fset.Position(pos).Offset
+		starts = append(starts, strings.LastIndexByte(src[:offset], '\n')+1)
+	}
+	if len(starts) == 0 {
+		return "", nil, false
+	}
+	return src[:starts[0]], cutAt(src, starts), true
+}
+
+// splitBlocks splits text before every unindented line that follows a blank
+// line, except lines closing a bracket.
+func splitBlocks(text string) []string {
+	var starts []int
+	blank := false
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			blank = true
+		default:
+			if blank && line[0] != ' ' && line[0] != '\t' && !strings.ContainsAny(line[:1], ")]}") && offset > 0 {
+				starts = append(starts, offset)
+			}
+			blank = false
+		}
+		offset += len(line)
+	}
+	if len(starts) == 0 {
+		return nil
+	}
+	return cutAt(text, append([]int{0}, starts...))
+}
+
+// cutAt returns the pieces of s between consecutive offsets of starts, the
+// last one running to the end of s.
+func cutAt(s string, starts []int) []string {
+	parts := make([]string, len(starts))
+	for i, start := range starts {
+		end := len(s)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		parts[i] = s[start:end]
+	}
+	return parts
+}
@@ -0,0 +1,82 @@
+package extractor
+
+import (
+	"strings"
+)
+
+// extractStripped returns a source file without its comments, blank lines
+// and trailing whitespace. String literals, including docstrings, are kept
+// intact.
+func extractStripped(f *File) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Text: stripComments(string(data), commentSyntaxes[f.Ext])}
+	result.Header, result.Parts = splitSource(f.Ext, result.Text)
+	return result, nil
+}
+
+// stripComments removes the comments of src, then its blank lines and the
+// trailing whitespace of its lines. A comment between two tokens on a line
+// is replaced by a space to keep them apart.
+func stripComments(src string, syn *commentSyntax) string {
+	comments, literals := scanSource(src, syn)
+
+	var out strings.Builder
+	var line []byte
+	keep := 0          // bytes of line belonging to a string literal, not to be trimmed
+	protected := false // line holds string literal content
+	flush := func() {
+		end := len(line)
+		for end > keep && (line[end-1] == ' ' || line[end-1] == '\t' || line[end-1] == '\r') {
+			end--
+		}
+		if end > 0 || protected {
+			out.Write(line[:end])
+			out.WriteByte('\n')
+		}
+		line, keep, protected = line[:0], 0, false
+	}
+
+	ci, li := 0, 0
+	for i := 0; i < len(src); {
+		for ci < len(comments) && (comments[ci].docstring || comments[ci].start < i) {
+			ci++
+		}
+		for li < len(literals) && literals[li][0] < i {
+			li++
+		}
+
+		switch {
+		case ci < len(comments) && comments[ci].start == i:
+			end := comments[ci].end
+			if len(line) > 0 && !isSpace(line[len(line)-1]) && end < len(src) && !isSpace(src[end]) {
+				line = append(line, ' ')
+			}
+			i = end
+		case li < len(literals) && literals[li][0] == i:
+			for _, c := range []byte(src[i:literals[li][1]]) {
+				if c == '\n' {
+					out.Write(line)
+					out.WriteByte('\n')
+					line = line[:0]
+					continue
+				}
+				line = append(line, c)
+			}
+			keep, protected = len(line), true
+			i = literals[li][1]
+		case src[i] == '\n':
+			flush()
+			i++
+		default:
+			line = append(line, src[i])
+			i++
+		}
+	}
+	if len(line) > 0 {
+		flush()
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
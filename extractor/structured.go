@@ -0,0 +1,199 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// StructuredOptions controls how JSON, YAML and TOML documents are converted
+// to text.
+type StructuredOptions struct {
+	Mode          string // "flatten" to path = value lines, "pretty" to indent JSON, "" to keep as is
+	StringsOnly   bool   // emit only string values, without their paths
+	MaxArrayItems int    // array elements emitted per array, 0 for all
+}
+
+// extractStructured decodes a JSON, YAML or TOML document and returns it
+// flattened or pretty-printed as configured by opts.
+func extractStructured(f *File, opts StructuredOptions) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Mode == "pretty" {
+		if f.Ext != ".json" && f.Ext != ".jsonl" && f.Ext != ".ndjson" {
+			return &Result{Text: string(data)}, nil
+		}
+		text, err := indentJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Text: text}, nil
+	}
+
+	docs, err := decodeStructured(f.Ext, data)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		var lines []string
+		flattenValue("", doc, opts, &lines)
+		texts = append(texts, strings.Join(lines, "\n"))
+	}
+	return &Result{Text: strings.Join(texts, "\n\n")}, nil
+}
+
+// indentJSON returns every JSON document in data indented by two spaces.
+func indentJSON(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var docs []string
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return strings.Join(docs, "\n"), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, raw, "", "  "); err != nil {
+			return "", err
+		}
+		docs = append(docs, buf.String())
+	}
+}
+
+// decodeStructured decodes every document in data according to the format
+// implied by ext. JSON Lines and multi-document YAML streams yield several.
+func decodeStructured(ext string, data []byte) ([]interface{}, error) {
+	var docs []interface{}
+
+	switch ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var doc interface{}
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				return docs, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	case ".toml":
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return []interface{}{doc}, nil
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		for {
+			var doc interface{}
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				return docs, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	}
+}
+
+// flattenValue appends the "path = value" lines of v, found at path, to lines.
+func flattenValue(path string, v interface{}, opts StructuredOptions, lines *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			emitLeaf(path, "{}", false, opts, lines)
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenValue(joinKey(path, k), v[k], opts, lines)
+		}
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			converted[fmt.Sprint(k)] = item
+		}
+		flattenValue(path, converted, opts, lines)
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		flattenValue(path, items, opts, lines)
+	case []interface{}:
+		if len(v) == 0 {
+			emitLeaf(path, "[]", false, opts, lines)
+			return
+		}
+		for i, item := range v {
+			if opts.MaxArrayItems > 0 && i == opts.MaxArrayItems {
+				emitLeaf(path, fmt.Sprintf("... (%d more items)", len(v)-i), false, opts, lines)
+				break
+			}
+			flattenValue(path+"["+strconv.Itoa(i)+"]", item, opts, lines)
+		}
+	case string:
+		emitLeaf(path, v, true, opts, lines)
+	case nil:
+		emitLeaf(path, "null", false, opts, lines)
+	default:
+		emitLeaf(path, fmt.Sprint(v), false, opts, lines)
+	}
+}
+
+// emitLeaf appends one flattened value to lines. With StringsOnly, only
+// string values are kept and their paths are left out; otherwise newlines
+// in strings are escaped to keep one value per line.
+func emitLeaf(path, value string, isString bool, opts StructuredOptions, lines *[]string) {
+	if opts.StringsOnly {
+		if isString && strings.TrimSpace(value) != "" {
+			*lines = append(*lines, value)
+		}
+		return
+	}
+	if isString {
+		value = strings.ReplaceAll(value, "\n", `\n`)
+	}
+	if path == "" {
+		*lines = append(*lines, value)
+		return
+	}
+	*lines = append(*lines, path+" = "+value)
+}
+
+// joinKey appends key to path, quoting keys that would make the path
+// ambiguous.
+func joinKey(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]\"= \t\n") {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
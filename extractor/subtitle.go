@@ -0,0 +1,192 @@
+package extractor
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubtitleOptions controls how SRT and WebVTT captions are converted to text.
+type SubtitleOptions struct {
+	TimestampInterval time.Duration // insert a [hh:mm:ss] marker at most this often, 0 for none
+}
+
+// subtitleParagraphGap is the pause between two cues that starts a new
+// paragraph.
+const subtitleParagraphGap = 3 * time.Second
+
+var (
+	subtitleVoiceTag = regexp.MustCompile(`<v(?:\.[^ >]*)?\s+([^>]+)>`)
+	subtitleTag      = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+)
+
+// subtitleCue is one caption with its start and end times.
+type subtitleCue struct {
+	start, end time.Duration
+	speaker    string
+	text       string
+}
+
+// extractSubtitles returns the captions of an SRT or WebVTT file as
+// paragraphs, without cue numbers, timings or styling. Consecutive cues are
+// merged until a pause or a change of speaker.
+func extractSubtitles(f *File, opts SubtitleOptions) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	cues := parseCues(string(data))
+
+	var paragraphs []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, " "))
+			current = nil
+		}
+	}
+
+	var nextMark time.Duration
+	var prev *subtitleCue
+	for i := range cues {
+		cue := &cues[i]
+		if opts.TimestampInterval > 0 && cue.start >= nextMark {
+			flush()
+			paragraphs = append(paragraphs, "["+formatCueTime(cue.start)+"]")
+			nextMark = (cue.start/opts.TimestampInterval + 1) * opts.TimestampInterval
+		} else if prev != nil && (cue.start-prev.end >= subtitleParagraphGap || cue.speaker != prev.speaker) {
+			flush()
+		}
+
+		text := cue.text
+		if prev != nil && text == prev.text {
+			continue
+		}
+		if cue.speaker != "" && len(current) == 0 {
+			text = cue.speaker + ": " + text
+		}
+		current = append(current, text)
+		prev = cue
+	}
+	flush()
+
+	return &Result{
+		Text:     joinSubtitleParagraphs(paragraphs),
+		Metadata: map[string]string{"cues": strconv.Itoa(len(cues))},
+	}, nil
+}
+
+// joinSubtitleParagraphs separates paragraphs by blank lines, keeping each
+// timestamp marker directly above the paragraph that follows it.
+func joinSubtitleParagraphs(paragraphs []string) string {
+	var sb strings.Builder
+	for i, p := range paragraphs {
+		if i > 0 {
+			if strings.HasPrefix(paragraphs[i-1], "[") && strings.HasSuffix(paragraphs[i-1], "]") {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+// parseCues parses the cues of an SRT or WebVTT document. Blocks without a
+// timing line, such as the WEBVTT header, NOTE and STYLE blocks, are skipped.
+func parseCues(s string) []subtitleCue {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+	s = strings.TrimPrefix(s, "\ufeff")
+
+	var cues []subtitleCue
+	for _, block := range strings.Split(s, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		start, end, ok := parseCueTiming(lines[timing])
+		if !ok {
+			continue
+		}
+
+		cue := subtitleCue{start: start, end: end}
+		var texts []string
+		for _, line := range lines[timing+1:] {
+			if m := subtitleVoiceTag.FindStringSubmatch(line); m != nil && cue.speaker == "" {
+				cue.speaker = strings.TrimSpace(m[1])
+			}
+			line = strings.TrimSpace(html.UnescapeString(subtitleTag.ReplaceAllString(line, "")))
+			if line != "" {
+				texts = append(texts, line)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+		cue.text = strings.Join(texts, " ")
+		cues = append(cues, cue)
+	}
+	return cues
+}
+
+// parseCueTiming parses a "start --> end [settings]" timing line.
+func parseCueTiming(line string) (time.Duration, time.Duration, bool) {
+	parts := strings.SplitN(line, "-->", 2)
+	start, ok := parseCueTime(strings.TrimSpace(parts[0]))
+	if !ok {
+		return 0, 0, false
+	}
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return 0, 0, false
+	}
+	end, ok := parseCueTime(fields[0])
+	return start, end, ok
+}
+
+// parseCueTime parses an "hh:mm:ss,mmm" (SRT) or "[hh:]mm:ss.mmm" (WebVTT)
+// timestamp.
+func parseCueTime(s string) (time.Duration, bool) {
+	s = strings.Replace(s, ",", ".", 1)
+	var frac time.Duration
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		ms, err := strconv.Atoi((s[i+1:] + "000")[:3])
+		if err != nil {
+			return 0, false
+		}
+		frac = time.Duration(ms) * time.Millisecond
+		s = s[:i]
+	}
+
+	fields := strings.Split(s, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, false
+		}
+		total = total*60 + time.Duration(n)
+	}
+	return total*time.Second + frac, true
+}
+
+// formatCueTime formats d as hh:mm:ss.
+func formatCueTime(d time.Duration) string {
+	secs := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
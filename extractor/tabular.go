@@ -0,0 +1,95 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TabularOptions controls how CSV and TSV files are converted to text.
+type TabularOptions struct {
+	Records bool // render each row as "column: value" lines instead of a table
+}
+
+// extractTabular parses a CSV or TSV file and returns its rows as parts, so
+// that the text is only split between rows. In table mode the header row is
+// repeated at the start of every piece; in record mode every row is
+// rendered with its column names.
+func extractTabular(f *File, opts TabularOptions) (*Result, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	comma := ','
+	if f.Ext == ".tsv" || f.Ext == ".tab" {
+		comma = '\t'
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return &Result{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if opts.Records {
+			parts = append(parts, recordText(header, row)+"\n\n")
+		} else {
+			parts = append(parts, rowText(row, comma)+"\n")
+		}
+	}
+
+	result := &Result{
+		Parts:    parts,
+		Metadata: map[string]string{"rows": strconv.Itoa(len(parts))},
+	}
+	if !opts.Records {
+		result.Header = rowText(header, comma) + "\n"
+	}
+	result.Text = result.Header + strings.Join(parts, "")
+	return result, nil
+}
+
+// rowText renders a row with the given delimiter, quoting fields as needed,
+// without a line terminator.
+func rowText(row []string, comma rune) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	w.Write(row)
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// recordText renders a row as one "column: value" line per non-empty field.
+func recordText(header, row []string) string {
+	lines := make([]string, 0, len(row))
+	for i, value := range row {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		column := "column " + strconv.Itoa(i+1)
+		if i < len(header) && strings.TrimSpace(header[i]) != "" {
+			column = strings.TrimSpace(header[i])
+		}
+		lines = append(lines, column+": "+value)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,91 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// openZip reads f as a zip archive.
+func openZip(f *File) (*zip.Reader, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// readZipEntry returns the content of the named entry in the zip archive.
+func readZipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+// attr returns the value of the attribute with the given local name.
+func attr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// textBuilder accumulates extracted text while collapsing the blank lines
+// produced by nested block elements.
+type textBuilder struct {
+	sb strings.Builder
+}
+
+// WriteString appends s to the text.
+func (b *textBuilder) WriteString(s string) {
+	b.sb.WriteString(s)
+}
+
+// Newline ends the current line unless the text already ends with one.
+func (b *textBuilder) Newline() {
+	s := b.sb.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		b.sb.WriteByte('\n')
+	}
+}
+
+// String returns the accumulated text with trailing whitespace removed from
+// every line and runs of blank lines collapsed.
+func (b *textBuilder) String() string {
+	return normalizeLines(b.sb.String())
+}
+
+// normalizeLines trims trailing whitespace from every line of s, collapses
+// consecutive blank lines into one and drops leading and trailing blank lines.
+func normalizeLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
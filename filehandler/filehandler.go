@@ -1,6 +1,7 @@
 package filehandler
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -110,6 +111,35 @@ func CountWords(content string) int {
 	return wordCount
 }
 
+// CountWordsReader counts the words in the content read from r as
+// CountWords does, without holding the content in memory.
+func CountWordsReader(r io.Reader) (int, error) {
+	buf := make([]byte, 32*1024)
+	wordCount := 0
+	inWord := false
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			// Word characters are ASCII, so bytes of multi-byte
+			// characters never are.
+			if isWordChar(rune(b)) {
+				if !inWord {
+					inWord = true
+					wordCount++
+				}
+			} else {
+				inWord = false
+			}
+		}
+		if err == io.EOF {
+			return wordCount, nil
+		}
+		if err != nil {
+			return wordCount, err
+		}
+	}
+}
+
 // isWordChar returns true if the given rune is a valid word character.
 func isWordChar(r rune) bool {
 	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Changes compares the files of the work tree with those of a commit.
+type Changes struct {
+	repo       *Repository
+	files      map[string]Hash // regular files of the commit
+	submodules map[string]bool // submodule paths of the commit
+	index      map[string]indexEntry
+	indexTime  time.Time // modification time of the index file
+	ignore     *ignorer
+}
+
+// Compare prepares the comparison of the work tree with the commit h.
+func (r *Repository) Compare(h Hash) (*Changes, error) {
+	if r.workTree == "" {
+		return nil, fmt.Errorf("%s has no work tree", r.gitDir)
+	}
+	files, err := r.Files(h)
+	if err != nil {
+		return nil, err
+	}
+	c := &Changes{repo: r, files: map[string]Hash{}, submodules: map[string]bool{}, ignore: newIgnorer(r)}
+	for _, f := range files {
+		switch {
+		case f.Regular():
+			c.files[f.Path] = f.Hash
+		case f.Mode == ModeSubmodule:
+			c.submodules[f.Path] = true
+		}
+	}
+	if c.index, c.indexTime, err = r.readIndex(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Check reports whether the file at path, described by info, was added or
+// modified in the work tree since the commit, and returns the blob it had
+// in the commit, zero for added files. Files in .git directories and
+// submodules are never reported, nor untracked files that are ignored.
+func (c *Changes) Check(path string, info os.FileInfo) (Hash, bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Hash{}, false, err
+	}
+	rel, err := filepath.Rel(c.repo.workTree, abs)
+	if err != nil {
+		return Hash{}, false, err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return Hash{}, false, fmt.Errorf("%s is outside the work tree %s", path, c.repo.workTree)
+	}
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		if part == ".git" || c.submodules[strings.Join(parts[:i], "/")] {
+			return Hash{}, false, nil
+		}
+	}
+
+	old, tracked := c.files[rel]
+	if !tracked {
+		if _, staged := c.index[rel]; staged {
+			return Hash{}, true, nil
+		}
+		return Hash{}, !c.ignore.ignored(rel), nil
+	}
+
+	// The hash staged in the index holds for the file when its size and
+	// modification time still match and it was not modified in the same
+	// instant as the index was written.
+	if e, ok := c.index[rel]; ok && e.Size == uint32(info.Size()) &&
+		e.ModTime.Equal(info.ModTime()) && info.ModTime().Before(c.indexTime) {
+		return old, e.Hash != old, nil
+	}
+	current, err := hashFile(path, info.Size())
+	if err != nil {
+		return Hash{}, false, err
+	}
+	return old, current != old, nil
+}
+
+// hashFile returns the name the file at path, of the given size, would have
+// as a blob.
+func hashFile(path string, size int64) (Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Hash{}, err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return Hash{}, err
+	}
+	if n != size {
+		return Hash{}, fmt.Errorf("%s changed while being read", path)
+	}
+	var sum Hash
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,281 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around changes.
+	diffContext = 3
+	// maxDiffCost bounds the edit distance searched for a shortest diff of
+	// two ranges of lines; past it they are shown as replaced entirely.
+	maxDiffCost = 8192
+)
+
+// Diff returns the unified diff turning the text a, named oldName, into the
+// text b, named newName, or "" when they are equal. An empty oldName
+// stands for a file that did not exist, shown as /dev/null.
+func Diff(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	if oldName == "" {
+		oldName = "/dev/null"
+	}
+	linesA, linesB := splitLines(a), splitLines(b)
+	d := newDiffer(linesA, linesB)
+	d.compare(0, len(linesA), 0, len(linesB))
+
+	var sb strings.Builder
+	sb.WriteString("--- " + oldName + "\n+++ " + newName + "\n")
+	for _, h := range d.hunks() {
+		d.writeHunk(&sb, h, linesA, linesB)
+	}
+	return sb.String()
+}
+
+// splitLines splits text after each line break.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// differ finds the lines of a deleted and the lines of b inserted by a
+// shortest edit script, following Myers' linear space algorithm.
+type differ struct {
+	a, b              []int // lines numbered by content
+	deleted, inserted []bool
+}
+
+func newDiffer(linesA, linesB []string) *differ {
+	ids := map[string]int{}
+	number := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	return &differ{
+		a:        number(linesA),
+		b:        number(linesB),
+		deleted:  make([]bool, len(linesA)),
+		inserted: make([]bool, len(linesB)),
+	}
+}
+
+// compare marks the changes between a[aLo:aHi] and b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	if aLo < aHi && bLo < bHi {
+		if x, y, ok := d.bisect(aLo, aHi, bLo, bHi); ok {
+			d.compare(aLo, x, bLo, y)
+			d.compare(x, aHi, y, bHi)
+			return
+		}
+	}
+	for i := aLo; i < aHi; i++ {
+		d.deleted[i] = true
+	}
+	for j := bLo; j < bHi; j++ {
+		d.inserted[j] = true
+	}
+}
+
+// bisect finds the middle of a shortest edit script of a[aLo:aHi] and
+// b[bLo:bHi], searching forwards from the start and backwards from the end
+// until the paths overlap. It fails when the edit distance exceeds
+// maxDiffCost.
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	if maxD > maxDiffCost {
+		maxD = maxDiffCost
+	}
+	offset := maxD + 1
+	v1 := make([]int, 2*offset+1)
+	v2 := make([]int, 2*offset+1)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0 // whether the forward path meets the backward one
+
+	// Diagonals running off the edges of the grid are no longer searched.
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+	for step := 0; step <= maxD; step++ {
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			i := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[i-1] < v1[i+1]) {
+				x1 = v1[i+1]
+			} else {
+				x1 = v1[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[i] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				j := offset + delta - k1
+				if j >= 0 && j < len(v2) && v2[j] != -1 && x1 >= n-v2[j] {
+					return aLo + x1, bLo + y1, true
+				}
+			}
+		}
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			i := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[i-1] < v2[i+1]) {
+				x2 = v2[i+1]
+			} else {
+				x2 = v2[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[i] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				j := offset + delta - k2
+				if j >= 0 && j < len(v1) && v1[j] != -1 {
+					x1 := v1[j]
+					y1 := x1 - (delta - k2)
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// hunk is a range of lines of both texts shown in the diff.
+type hunk struct {
+	aStart, aEnd, bStart, bEnd int
+}
+
+// hunks groups the changes with their context, merging hunks whose context
+// would overlap.
+func (d *differ) hunks() []hunk {
+	var hunks []hunk
+	i, j := 0, 0
+	for i < len(d.a) || j < len(d.b) {
+		if (i >= len(d.a) || !d.deleted[i]) && (j >= len(d.b) || !d.inserted[j]) {
+			i++
+			j++
+			continue
+		}
+		aStart, bStart := maxInt(i-diffContext, 0), maxInt(j-diffContext, 0)
+		for (i < len(d.a) && d.deleted[i]) || (j < len(d.b) && d.inserted[j]) {
+			if i < len(d.a) && d.deleted[i] {
+				i++
+			} else {
+				j++
+			}
+		}
+		if last := len(hunks) - 1; last >= 0 && hunks[last].aEnd+diffContext >= aStart {
+			hunks[last].aEnd, hunks[last].bEnd = i, j
+		} else {
+			hunks = append(hunks, hunk{aStart, i, bStart, j})
+		}
+	}
+	for k := range hunks {
+		trailing := minInt(diffContext, len(d.a)-hunks[k].aEnd)
+		hunks[k].aEnd += trailing
+		hunks[k].bEnd += trailing
+	}
+	return hunks
+}
+
+// writeHunk writes the header and lines of h, the lines of a being a and
+// those of b being b.
+func (d *differ) writeHunk(sb *strings.Builder, h hunk, a, b []string) {
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aEnd), hunkRange(h.bStart, h.bEnd))
+	i, j := h.aStart, h.bStart
+	for i < h.aEnd || j < h.bEnd {
+		switch {
+		case i < h.aEnd && d.deleted[i]:
+			writeLine(sb, '-', a[i])
+			i++
+		case j < h.bEnd && d.inserted[j]:
+			writeLine(sb, '+', b[j])
+			j++
+		default:
+			writeLine(sb, ' ', a[i])
+			i++
+			j++
+		}
+	}
+}
+
+// writeLine writes a line of a hunk, marking a last line without a line
+// break.
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// hunkRange formats the start line and length of a range of lines, the
+// start being the line before an empty range.
+func hunkRange(start, end int) string {
+	switch end - start {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
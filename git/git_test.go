@@ -0,0 +1,307 @@
+package git
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldName  string
+		a, b     string
+		expected string
+	}{
+		{name: "equal", oldName: "a/f", a: "x\n", b: "x\n", expected: ""},
+		{
+			name: "added file",
+			a:    "",
+			b:    "one\ntwo\n",
+			expected: "--- /dev/null\n+++ b/f\n" +
+				"@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name:    "changed line with context",
+			oldName: "a/f",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:       "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- a/f\n+++ b/f\n" +
+				"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:    "separate hunks",
+			oldName: "a/f",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:       "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			expected: "--- a/f\n+++ b/f\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+		{
+			name:    "merged hunks",
+			oldName: "a/f",
+			a:       "1\n2\n3\n4\n5\n6\n7\n",
+			b:       "one\n2\n3\n4\n5\n6\nseven\n",
+			expected: "--- a/f\n+++ b/f\n" +
+				"@@ -1,7 +1,7 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n-7\n+seven\n",
+		},
+		{
+			name:    "no newline at end",
+			oldName: "a/f",
+			a:       "x\ny",
+			b:       "x\ny\n",
+			expected: "--- a/f\n+++ b/f\n" +
+				"@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+y\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Diff(test.oldName, "b/f", test.a, test.b); got != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestDiffShortest checks the diffs of random texts against the longest
+// common subsequence.
+func TestDiffShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		d := newDiffer(a, b)
+		d.compare(0, len(a), 0, len(b))
+
+		var kept []string
+		for i, line := range a {
+			if !d.deleted[i] {
+				kept = append(kept, line)
+			}
+		}
+		var keptB []string
+		for j, line := range b {
+			if !d.inserted[j] {
+				keptB = append(keptB, line)
+			}
+		}
+		if strings.Join(kept, "") != strings.Join(keptB, "") {
+			t.Fatalf("Unchanged lines differ for %q and %q", a, b)
+		}
+		if len(kept) != lcs(a, b) {
+			t.Fatalf("Expected %d unchanged lines for %q and %q, got %d", lcs(a, b), a, b, len(kept))
+		}
+	}
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] > dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestIgnore(t *testing.T) {
+	ig := &ignorer{patterns: map[string][]ignorePattern{
+		"":    parseIgnore("*.log\n!keep.log\nbuild/\n/root.txt\ndocs/**/draft.md\n\\#hash\n"),
+		"sub": parseIgnore("local.txt\n"),
+	}}
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"a.log", true},
+		{"x/a.log", true},
+		{"keep.log", false},
+		{"build/out.txt", true},
+		{"x/build/out.txt", true},
+		{"build", false}, // a file named build
+		{"root.txt", true},
+		{"x/root.txt", false},
+		{"docs/draft.md", true},
+		{"docs/a/b/draft.md", true},
+		{"#hash", true},
+		{"sub/local.txt", true},
+		{"local.txt", false},
+		{"main.go", false},
+	}
+	for _, test := range tests {
+		if got := ig.ignored(test.path); got != test.expected {
+			t.Errorf("ignored(%q) = %v, expected %v", test.path, got, test.expected)
+		}
+	}
+}
+
+func TestRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_CONFIG_NOSYSTEM=1", "HOME="+dir)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q", "-b", "main")
+	long := strings.Repeat("a line of text that deltas can share\n", 200)
+	write("a.txt", "first\n")
+	write("docs/long.md", long)
+	write(".gitignore", "*.log\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "first")
+	run("tag", "-a", "v1", "-m", "version 1")
+	write("docs/long.md", long+"one more line\n")
+	write("b.txt", "second\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "second")
+	run("branch", "feature")
+
+	check := func(t *testing.T) {
+		repo, err := Discover(filepath.Join(dir, "docs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer repo.Close()
+
+		for _, rev := range []string{"HEAD", "main", "feature", "v1", "HEAD~1", "HEAD^", "main~0", "HEAD^1", run("rev-parse", "HEAD")[:7]} {
+			h, err := repo.Resolve(rev)
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", rev, err)
+			}
+			if expected := run("rev-parse", rev+"^{commit}"); h.String() != expected {
+				t.Errorf("Resolve(%q) = %s, expected %s", rev, h, expected)
+			}
+		}
+		if _, err := repo.Resolve("missing"); err == nil {
+			t.Error("Expected an error for an unknown revision")
+		}
+
+		h, _ := repo.Resolve("HEAD")
+		files, err := repo.Files(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		expected := strings.Split(run("ls-tree", "-r", "--name-only", "HEAD"), "\n")
+		sort.Strings(expected)
+		if strings.Join(paths, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected files %v, got %v", expected, paths)
+		}
+		for _, f := range files {
+			data, err := repo.Blob(f.Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := run("cat-file", "blob", f.Hash.String()); strings.TrimSpace(string(data)) != expected {
+				t.Errorf("Unexpected content of %s: %q", f.Path, data)
+			}
+			if size, err := repo.Size(f.Hash); err != nil || size != int64(len(data)) {
+				t.Errorf("Size of %s = %d, %v; expected %d", f.Path, size, err, len(data))
+			}
+		}
+	}
+	t.Run("loose objects", check)
+	run("gc", "-q", "--aggressive")
+	if packs, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.pack")); len(packs) == 0 {
+		t.Fatal("Expected git gc to write a pack")
+	}
+	t.Run("packed objects", check)
+
+	// Changes of the work tree since the first commit.
+	write("a.txt", "changed\n")
+	write("c.txt", "untracked\n")
+	write("d.txt", "staged\n")
+	write("debug.log", "ignored\n")
+	run("add", "d.txt")
+	repo, err := Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	h, err := repo.Resolve("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := repo.Compare(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		changed bool
+		added   bool
+	}{
+		{path: "a.txt", changed: true},
+		{path: "b.txt", changed: true, added: true},
+		{path: "c.txt", changed: true, added: true},
+		{path: "d.txt", changed: true, added: true},
+		{path: "docs/long.md", changed: true},
+		{path: ".gitignore"},
+		{path: "debug.log"},
+		{path: ".git/HEAD"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.path)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		old, changed, err := changes.Check(path, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != test.changed || (changed && old.IsZero() != test.added) {
+			t.Errorf("Check(%s) = %s, %v; expected changed %v, added %v", test.path, old, changed, test.changed, test.added)
+		}
+	}
+}
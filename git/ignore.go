@@ -0,0 +1,159 @@
+package git
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is a line of a .gitignore file.
+type ignorePattern struct {
+	re      *regexp.Regexp // matches paths relative to the directory of the file
+	negate  bool           // "!" pattern re-including what earlier ones excluded
+	dirOnly bool           // pattern ending with "/"
+}
+
+// ignorer matches paths of the work tree against the .gitignore files and
+// .git/info/exclude.
+type ignorer struct {
+	root     string
+	patterns map[string][]ignorePattern // by slash-separated directory, "" for the root
+}
+
+func newIgnorer(r *Repository) *ignorer {
+	ig := &ignorer{root: r.workTree, patterns: map[string][]ignorePattern{}}
+	exclude, _ := ioutil.ReadFile(filepath.Join(r.commonDir, "info", "exclude"))
+	ig.patterns[""] = append(parseIgnore(string(exclude)), ig.load("")...)
+	return ig
+}
+
+// load reads the .gitignore file of dir.
+func (ig *ignorer) load(dir string) []ignorePattern {
+	data, err := ioutil.ReadFile(filepath.Join(ig.root, filepath.FromSlash(dir), ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	return parseIgnore(string(data))
+}
+
+// dirPatterns returns the patterns of the .gitignore file of dir.
+func (ig *ignorer) dirPatterns(dir string) []ignorePattern {
+	patterns, ok := ig.patterns[dir]
+	if !ok {
+		patterns = ig.load(dir)
+		ig.patterns[dir] = patterns
+	}
+	return patterns
+}
+
+// ignored reports whether the slash-separated path is ignored, itself or
+// through one of its parent directories.
+func (ig *ignorer) ignored(path string) bool {
+	parts := strings.Split(path, "/")
+	for i := 1; i <= len(parts); i++ {
+		if ig.match(parts[:i], i < len(parts)) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the last pattern matching the path made of parts
+// excludes it. Patterns of deeper .gitignore files come later.
+func (ig *ignorer) match(parts []string, dir bool) bool {
+	ignored := false
+	for depth := 0; depth < len(parts); depth++ {
+		rel := strings.Join(parts[depth:], "/")
+		for _, p := range ig.dirPatterns(strings.Join(parts[:depth], "/")) {
+			if (!p.dirOnly || dir) && p.re.MatchString(rel) {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// parseIgnore parses the content of a .gitignore file.
+func parseIgnore(content string) []ignorePattern {
+	var patterns []ignorePattern
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimSuffix(line, " ")
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.re = ignoreRegexp(line)
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// ignoreRegexp converts a .gitignore pattern to a regular expression. A
+// pattern with a "/" is anchored to the directory of its file; one without
+// matches a name at any depth. "**" matches any number of directories.
+func ignoreRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(pattern) + `$`)
+	}
+	return re
+}
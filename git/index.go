@@ -0,0 +1,123 @@
+package git
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexEntry is a stage 0 entry of the index: the blob staged for a path,
+// with the size and modification time the file had when it was hashed.
+type indexEntry struct {
+	Hash    Hash
+	Size    uint32 // size truncated to 32 bits
+	ModTime time.Time
+}
+
+// readIndex reads the index of the repository, by path. A repository
+// without an index has no entries.
+func (r *Repository) readIndex() (map[string]indexEntry, time.Time, error) {
+	path := filepath.Join(r.gitDir, "index")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return map[string]indexEntry{}, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	entries, err := parseIndex(data)
+	return entries, info.ModTime(), err
+}
+
+// parseIndex parses an index file of version 2, 3 or 4.
+func parseIndex(data []byte) (map[string]indexEntry, error) {
+	invalid := errors.New("invalid index")
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, invalid
+	}
+	version := binary.BigEndian.Uint32(data[4:])
+	if version < 2 || version > 4 {
+		return nil, errors.New("unsupported index version")
+	}
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	entries := make(map[string]indexEntry, n)
+
+	pos := 12
+	var name []byte
+	for i := 0; i < n; i++ {
+		start := pos
+		if len(data) < pos+62 {
+			return nil, invalid
+		}
+		mtime := time.Unix(int64(binary.BigEndian.Uint32(data[pos+8:])), int64(binary.BigEndian.Uint32(data[pos+12:])))
+		entry := indexEntry{Size: binary.BigEndian.Uint32(data[pos+36:]), ModTime: mtime}
+		copy(entry.Hash[:], data[pos+40:pos+60])
+		flags := binary.BigEndian.Uint16(data[pos+60:])
+		pos += 62
+		if version >= 3 && flags&0x4000 != 0 {
+			pos += 2 // extended flags
+		}
+		if pos > len(data) {
+			return nil, invalid
+		}
+
+		if version == 4 {
+			// The name is stored as the number of bytes to drop from the
+			// end of the previous name and the bytes to append to it.
+			strip, size := offsetVarint(data[pos:])
+			if size == 0 || strip > len(name) {
+				return nil, invalid
+			}
+			pos += size
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, invalid
+			}
+			name = append(name[:len(name)-strip:len(name)-strip], data[pos:pos+end]...)
+			pos += end + 1
+		} else {
+			end := bytes.IndexByte(data[pos:], 0)
+			if end < 0 {
+				return nil, invalid
+			}
+			name = data[pos : pos+end]
+			// Entries are padded with 1 to 8 NUL bytes to a multiple of 8.
+			pos = start + (pos+end-start+8)&^7
+		}
+
+		if stage := flags >> 12 & 3; stage == 0 {
+			entries[string(name)] = entry
+		}
+	}
+	return entries, nil
+}
+
+// offsetVarint decodes the variable-length integer used for the offsets of
+// packed deltas and the names of version 4 index entries, returning it with
+// the number of bytes read, 0 when data is truncated.
+func offsetVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	c := data[0]
+	n := int(c & 0x7f)
+	for i := 1; c&0x80 != 0; i++ {
+		if i == len(data) {
+			return 0, 0
+		}
+		c = data[i]
+		n = (n+1)<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			return n, i + 1
+		}
+	}
+	return n, 1
+}
@@ -0,0 +1,458 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDeltaDepth bounds the chains of deltas followed to read a packed
+// object, to stop on corrupt packs.
+const maxDeltaDepth = 1000
+
+// packObjectTypes names the object types stored in packs.
+var packObjectTypes = map[byte]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+// object returns the type and content of the object h, read from the loose
+// objects or the packs.
+func (r *Repository) object(h Hash) (string, []byte, error) {
+	name := h.String()
+	for _, dir := range r.objects {
+		typ, data, err := readLoose(filepath.Join(dir, name[:2], name[2:]))
+		if err == nil {
+			return typ, data, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", nil, fmt.Errorf("object %s: %w", h, err)
+		}
+	}
+	for _, p := range r.packs {
+		if offset, ok := p.find(h); ok {
+			typ, data, err := p.read(r, offset, 0)
+			if err != nil {
+				return "", nil, fmt.Errorf("object %s: %w", h, err)
+			}
+			return typ, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("object %s not found", h)
+}
+
+// Blob returns the content of the blob h.
+func (r *Repository) Blob(h Hash) ([]byte, error) {
+	typ, data, err := r.object(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != "blob" {
+		return nil, fmt.Errorf("%s is a %s, not a blob", h, typ)
+	}
+	return data, nil
+}
+
+// Size returns the size of the content of the object h, reading no more
+// of it than its header.
+func (r *Repository) Size(h Hash) (int64, error) {
+	name := h.String()
+	for _, dir := range r.objects {
+		f, err := os.Open(filepath.Join(dir, name[:2], name[2:]))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		_, size, br, err := looseHeader(f)
+		if err != nil {
+			return 0, fmt.Errorf("object %s: %w", h, err)
+		}
+		br.Close()
+		return size, nil
+	}
+	for _, p := range r.packs {
+		if offset, ok := p.find(h); ok {
+			size, err := p.size(offset)
+			if err != nil {
+				return 0, fmt.Errorf("object %s: %w", h, err)
+			}
+			return size, nil
+		}
+	}
+	return 0, fmt.Errorf("object %s not found", h)
+}
+
+// readLoose reads the loose object stored in the file at path.
+func readLoose(path string) (string, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	typ, size, rc, err := looseHeader(f)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return "", nil, err
+	}
+	return typ, data, nil
+}
+
+// looseHeader reads the header of the loose object read from r, returning
+// its type and size and the reader of its content.
+func looseHeader(r io.Reader) (string, int64, io.ReadCloser, error) {
+	zr, err := zlib.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return "", 0, nil, err
+	}
+	invalid := errors.New("invalid loose object header")
+	br := bufio.NewReader(zr)
+	head, err := br.ReadString(0)
+	if err != nil {
+		zr.Close()
+		return "", 0, nil, invalid
+	}
+	fields := strings.Fields(strings.TrimSuffix(head, "\x00"))
+	if len(fields) != 2 {
+		zr.Close()
+		return "", 0, nil, invalid
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		zr.Close()
+		return "", 0, nil, invalid
+	}
+	return fields[0], size, struct {
+		io.Reader
+		io.Closer
+	}{br, zr}, nil
+}
+
+// pack is a pack file with its version 2 index.
+type pack struct {
+	file    *os.File
+	fanout  [256]uint32
+	names   []byte // sorted object names, 20 bytes each
+	offsets []byte // 4-byte offsets in the pack, or indexes into large
+	large   []byte // 8-byte offsets beyond 2 GiB
+}
+
+// openPack opens the pack whose index is at indexPath.
+func openPack(indexPath string) (*pack, error) {
+	data, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	invalid := fmt.Errorf("invalid pack index %s", indexPath)
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], []byte("\xfftOc")) {
+		return nil, fmt.Errorf("unsupported pack index %s: only version 2 is read", indexPath)
+	}
+	if version := binary.BigEndian.Uint32(data[4:]); version != 2 {
+		return nil, fmt.Errorf("unsupported pack index %s: version %d", indexPath, version)
+	}
+	p := &pack{}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+4*i:])
+	}
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(data) < pos+n*(20+4+4)+40 {
+		return nil, invalid
+	}
+	p.names = data[pos : pos+20*n]
+	pos += 20*n + 4*n // names and CRCs
+	p.offsets = data[pos : pos+4*n]
+	p.large = data[pos+4*n : len(data)-40]
+
+	if p.file, err = os.Open(strings.TrimSuffix(indexPath, ".idx") + ".pack"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pack) close() error {
+	return p.file.Close()
+}
+
+// find returns the offset of the object h in the pack.
+func (p *pack) find(h Hash) (int64, bool) {
+	lo, hi := 0, int(p.fanout[h[0]])
+	if h[0] > 0 {
+		lo = int(p.fanout[h[0]-1])
+	}
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.names[20*(lo+i):20*(lo+i+1)], h[:]) >= 0
+	})
+	if i == hi || !bytes.Equal(p.names[20*i:20*(i+1)], h[:]) {
+		return 0, false
+	}
+	offset := binary.BigEndian.Uint32(p.offsets[4*i:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	j := int(offset&0x7fffffff) * 8
+	if j+8 > len(p.large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[j:])), true
+}
+
+// withPrefix returns the names of the objects in the pack starting with the
+// hexadecimal prefix, at least two digits long.
+func (p *pack) withPrefix(prefix string) []Hash {
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+	lo, hi := 0, int(p.fanout[first[0]])
+	if first[0] > 0 {
+		lo = int(p.fanout[first[0]-1])
+	}
+	var found []Hash
+	for i := lo; i < hi; i++ {
+		var h Hash
+		copy(h[:], p.names[20*i:])
+		if strings.HasPrefix(h.String(), prefix) {
+			found = append(found, h)
+		}
+	}
+	return found
+}
+
+// header reads the type and size of the object at offset, followed for
+// deltas by the reference to their base, from the returned reader.
+func (p *pack) header(offset int64) (byte, int64, *bufio.Reader, error) {
+	br := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	typ := (c >> 4) & 7
+	size := int64(c & 15)
+	for shift := uint(4); c&0x80 != 0; shift += 7 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+	return typ, size, br, nil
+}
+
+// size returns the size of the content of the object at offset. The size
+// of a deltified object is read from the start of its delta.
+func (p *pack) size(offset int64) (int64, error) {
+	typ, size, br, err := p.header(offset)
+	if err != nil {
+		return 0, err
+	}
+	switch typ {
+	case 6:
+		for {
+			c, err := br.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			if c&0x80 == 0 {
+				break
+			}
+		}
+	case 7:
+		if _, err := br.Discard(len(Hash{})); err != nil {
+			return 0, err
+		}
+	default:
+		return size, nil
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	delta := bufio.NewReader(zr)
+	var n int64
+	for i := 0; i < 2; i++ { // the size of the base, then of the object
+		n = 0
+		for shift := uint(0); ; shift += 7 {
+			c, err := delta.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			n |= int64(c&0x7f) << shift
+			if c&0x80 == 0 {
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// read returns the type and content of the object at offset, applying its
+// deltas. Deltas against objects outside the pack are resolved through r.
+func (p *pack) read(r *Repository, offset int64, depth int) (string, []byte, error) {
+	if depth > maxDeltaDepth {
+		return "", nil, errors.New("delta chain too deep")
+	}
+	typ, size, br, err := p.header(offset)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var baseType string
+	var base []byte
+	switch typ {
+	case 6: // delta against the object at a lower offset in the pack
+		c, err := br.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			distance = (distance+1)<<7 | int64(c&0x7f)
+		}
+		if distance <= 0 || distance > offset {
+			return "", nil, errors.New("invalid delta base offset")
+		}
+		if baseType, base, err = p.read(r, offset-distance, depth+1); err != nil {
+			return "", nil, err
+		}
+	case 7: // delta against a named object
+		var h Hash
+		if _, err := io.ReadFull(br, h[:]); err != nil {
+			return "", nil, err
+		}
+		if baseType, base, err = r.object(h); err != nil {
+			return "", nil, err
+		}
+	default:
+		name, ok := packObjectTypes[typ]
+		if !ok {
+			return "", nil, fmt.Errorf("invalid object type %d", typ)
+		}
+		data, err := inflate(br, size)
+		return name, data, err
+	}
+
+	delta, err := inflate(br, size)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := applyDelta(base, delta)
+	return baseType, data, err
+}
+
+// inflate decompresses size bytes from r.
+func inflate(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// applyDelta rebuilds an object from the object base and a delta made of
+// instructions copying ranges of base and inserting new bytes.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	invalid := errors.New("invalid delta")
+	next := func() (byte, error) {
+		if len(delta) == 0 {
+			return 0, invalid
+		}
+		c := delta[0]
+		delta = delta[1:]
+		return c, nil
+	}
+	varint := func() (int, error) {
+		n, shift := 0, uint(0)
+		for {
+			c, err := next()
+			if err != nil {
+				return 0, err
+			}
+			n |= int(c&0x7f) << shift
+			if c&0x80 == 0 {
+				return n, nil
+			}
+			shift += 7
+		}
+	}
+
+	baseSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	size, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, invalid
+	}
+	out := make([]byte, 0, size)
+	for len(delta) > 0 {
+		op, _ := next()
+		switch {
+		case op&0x80 != 0:
+			var offset, n int
+			for i := uint(0); i < 4; i++ {
+				if op&(1<<i) != 0 {
+					c, err := next()
+					if err != nil {
+						return nil, err
+					}
+					offset |= int(c) << (8 * i)
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					c, err := next()
+					if err != nil {
+						return nil, err
+					}
+					n |= int(c) << (8 * i)
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > len(base) {
+				return nil, invalid
+			}
+			out = append(out, base[offset:offset+n]...)
+		case op > 0:
+			if int(op) > len(delta) {
+				return nil, invalid
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, invalid
+		}
+	}
+	if len(out) != size {
+		return nil, invalid
+	}
+	return out, nil
+}
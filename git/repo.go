@@ -0,0 +1,379 @@
+// Package git reads commits, trees and blobs from a git repository, and
+// compares its work tree against a commit, without the git command.
+package git
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Hash is the SHA-1 name of a git object.
+type Hash [20]byte
+
+// ParseHash parses a 40-digit hexadecimal object name.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 2*len(h) {
+		return h, fmt.Errorf("invalid object name %q", s)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid object name %q", s)
+	}
+	return h, nil
+}
+
+// String returns the hexadecimal form of h.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// IsZero reports whether h is the zero hash, used for missing objects.
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+// Repository is a git repository on disk.
+type Repository struct {
+	gitDir    string // HEAD and the index
+	commonDir string // objects and refs, the same as gitDir unless in a linked work tree
+	workTree  string // "" for bare repositories
+	objects   []string
+	packs     []*pack
+}
+
+// Discover opens the repository containing path, looking for a .git
+// directory or file in path and its parents, or the bare repository at path.
+func Discover(path string) (*Repository, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBare(dir) {
+		return open(dir, "")
+	}
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		if err == nil {
+			gitDir := dotGit
+			if !info.IsDir() {
+				if gitDir, err = readGitFile(dotGit); err != nil {
+					return nil, err
+				}
+			}
+			return open(gitDir, dir)
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("not a git repository: %s", path)
+		}
+		dir = parent
+	}
+}
+
+// Open opens the bare repository or .git directory at gitDir.
+func Open(gitDir string) (*Repository, error) {
+	gitDir, err := filepath.Abs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	workTree := ""
+	if filepath.Base(gitDir) == ".git" {
+		workTree = filepath.Dir(gitDir)
+	}
+	return open(gitDir, workTree)
+}
+
+// isBare reports whether dir looks like a bare repository.
+func isBare(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return filepath.Base(dir) != ".git"
+}
+
+// readGitFile returns the directory named by a "gitdir: path" .git file, as
+// written for linked work trees and submodules.
+func readGitFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("invalid .git file %s", path)
+	}
+	dir := strings.TrimPrefix(line, "gitdir: ")
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(path), dir)
+	}
+	return dir, nil
+}
+
+func open(gitDir, workTree string) (*Repository, error) {
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, fmt.Errorf("not a git repository: %s", gitDir)
+	}
+	r := &Repository{gitDir: gitDir, commonDir: gitDir, workTree: workTree}
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		dir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(gitDir, dir)
+		}
+		r.commonDir = dir
+	}
+
+	objects := filepath.Join(r.commonDir, "objects")
+	r.objects = append(r.objects, objects)
+	if data, err := ioutil.ReadFile(filepath.Join(objects, "info", "alternates")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(objects, line)
+			}
+			r.objects = append(r.objects, line)
+		}
+	}
+	for _, dir := range r.objects {
+		indexes, err := filepath.Glob(filepath.Join(dir, "pack", "pack-*.idx"))
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range indexes {
+			p, err := openPack(index)
+			if err != nil {
+				return nil, err
+			}
+			r.packs = append(r.packs, p)
+		}
+	}
+	return r, nil
+}
+
+// WorkTree returns the root of the work tree, or "" for a bare repository.
+func (r *Repository) WorkTree() string {
+	return r.workTree
+}
+
+// Close releases the pack files of the repository.
+func (r *Repository) Close() error {
+	var err error
+	for _, p := range r.packs {
+		if closeErr := p.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Resolve returns the commit named by rev: a full or abbreviated object
+// name, a branch, tag or other ref, or HEAD, optionally followed by "~n"
+// and "^n" suffixes selecting ancestors. Tags are peeled to their commit.
+func (r *Repository) Resolve(rev string) (Hash, error) {
+	base, suffix := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base, suffix = rev[:i], rev[i:]
+	}
+	if base == "" {
+		base = "HEAD"
+	}
+	h, err := r.resolveName(base)
+	if err != nil {
+		return Hash{}, err
+	}
+	if h, err = r.peel(h); err != nil {
+		return Hash{}, err
+	}
+
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(suffix[:digits]); err != nil {
+				return Hash{}, fmt.Errorf("invalid revision %q", rev)
+			}
+			suffix = suffix[digits:]
+		}
+		steps, parent := n, 1
+		if op == '^' {
+			steps, parent = 1, n
+		}
+		for i := 0; i < steps && parent > 0; i++ {
+			c, err := r.Commit(h)
+			if err != nil {
+				return Hash{}, err
+			}
+			if len(c.Parents) < parent {
+				return Hash{}, fmt.Errorf("revision %q does not exist", rev)
+			}
+			h = c.Parents[parent-1]
+		}
+	}
+	return h, nil
+}
+
+// resolveName returns the object named by a ref or object name.
+func (r *Repository) resolveName(name string) (Hash, error) {
+	if h, err := ParseHash(name); err == nil {
+		return h, nil
+	}
+	refs := []string{"refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"}
+	if strings.HasPrefix(name, "refs/") || strings.Trim(name, "ABCDEFGHIJKLMNOPQRSTUVWXYZ_") == "" {
+		// Full ref names, and names such as HEAD and ORIG_HEAD.
+		refs = append([]string{name}, refs...)
+	}
+	for _, ref := range refs {
+		h, err := r.readRef(ref, 0)
+		if err == nil {
+			return h, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return Hash{}, err
+		}
+	}
+	if len(name) >= 4 && strings.Trim(strings.ToLower(name), "0123456789abcdef") == "" {
+		return r.expand(strings.ToLower(name))
+	}
+	return Hash{}, fmt.Errorf("unknown revision %q", name)
+}
+
+// readRef returns the object the ref named name points to, following
+// symbolic refs. The error wraps os.ErrNotExist when the ref does not exist.
+func (r *Repository) readRef(name string, depth int) (Hash, error) {
+	if depth > 5 {
+		return Hash{}, fmt.Errorf("too many levels of symbolic refs at %s", name)
+	}
+	dir := r.commonDir
+	if !strings.HasPrefix(name, "refs/") {
+		dir = r.gitDir
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err == nil {
+		line := strings.TrimSpace(string(data))
+		if strings.HasPrefix(line, "ref: ") {
+			return r.readRef(strings.TrimPrefix(line, "ref: "), depth+1)
+		}
+		return ParseHash(line)
+	}
+	if !os.IsNotExist(err) && !isDirError(err) {
+		return Hash{}, err
+	}
+	return r.packedRef(name)
+}
+
+// isDirError reports whether err comes from reading a directory as a file,
+// as when a ref name is also the prefix of other refs.
+func isDirError(err error) bool {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		if info, statErr := os.Stat(pathErr.Path); statErr == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// packedRef looks name up in the packed-refs file.
+func (r *Repository) packedRef(name string) (Hash, error) {
+	notFound := fmt.Errorf("ref %s: %w", name, os.ErrNotExist)
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return Hash{}, notFound
+	}
+	if err != nil {
+		return Hash{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == name {
+			return ParseHash(fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Hash{}, err
+	}
+	return Hash{}, notFound
+}
+
+// expand returns the object whose name starts with the hexadecimal prefix.
+func (r *Repository) expand(prefix string) (Hash, error) {
+	found := map[Hash]bool{}
+	for _, dir := range r.objects {
+		names, err := ioutil.ReadDir(filepath.Join(dir, prefix[:2]))
+		if err != nil && !os.IsNotExist(err) {
+			return Hash{}, err
+		}
+		for _, info := range names {
+			if strings.HasPrefix(prefix[:2]+info.Name(), prefix) {
+				if h, err := ParseHash(prefix[:2] + info.Name()); err == nil {
+					found[h] = true
+				}
+			}
+		}
+	}
+	for _, p := range r.packs {
+		for _, h := range p.withPrefix(prefix) {
+			found[h] = true
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Hash{}, fmt.Errorf("unknown revision %q", prefix)
+	case 1:
+		for h := range found {
+			return h, nil
+		}
+	}
+	return Hash{}, fmt.Errorf("ambiguous object name %q", prefix)
+}
+
+// peel follows annotated tags from h to the commit they point to.
+func (r *Repository) peel(h Hash) (Hash, error) {
+	for i := 0; ; i++ {
+		typ, data, err := r.object(h)
+		if err != nil {
+			return Hash{}, err
+		}
+		switch typ {
+		case "commit":
+			return h, nil
+		case "tag":
+			if i > 10 {
+				return Hash{}, fmt.Errorf("too many levels of tags at %s", h)
+			}
+			target, ok := header(data, "object")
+			if !ok {
+				return Hash{}, fmt.Errorf("invalid tag %s", h)
+			}
+			if h, err = ParseHash(target); err != nil {
+				return Hash{}, err
+			}
+		default:
+			return Hash{}, fmt.Errorf("%s is a %s, not a commit", h, typ)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tree entry modes.
+const (
+	ModeFile       = 0100644
+	ModeExecutable = 0100755
+	ModeSymlink    = 0120000
+	ModeSubmodule  = 0160000
+	modeTree       = 040000
+)
+
+// Commit is the part of a commit object needed to walk history.
+type Commit struct {
+	Tree    Hash
+	Parents []Hash
+}
+
+// File is a file in the tree of a commit.
+type File struct {
+	Path string // slash-separated path from the root of the tree
+	Mode int
+	Hash Hash
+}
+
+// Regular reports whether the file is a regular or executable file rather
+// than a symbolic link or a submodule.
+func (f File) Regular() bool {
+	return f.Mode&0170000 == 0100000
+}
+
+// Commit reads the commit h.
+func (r *Repository) Commit(h Hash) (*Commit, error) {
+	typ, data, err := r.object(h)
+	if err != nil {
+		return nil, err
+	}
+	if typ != "commit" {
+		return nil, fmt.Errorf("%s is a %s, not a commit", h, typ)
+	}
+	c := &Commit{}
+	tree, ok := header(data, "tree")
+	if !ok {
+		return nil, fmt.Errorf("invalid commit %s", h)
+	}
+	if c.Tree, err = ParseHash(tree); err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(headers(data)), "\n") {
+		if strings.HasPrefix(line, "parent ") {
+			parent, err := ParseHash(strings.TrimPrefix(line, "parent "))
+			if err != nil {
+				return nil, err
+			}
+			c.Parents = append(c.Parents, parent)
+		}
+	}
+	return c, nil
+}
+
+// headers returns the header lines of a commit or tag object, before the
+// blank line starting the message.
+func headers(data []byte) []byte {
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		return data[:i]
+	}
+	return data
+}
+
+// header returns the value of the first header named key of a commit or
+// tag object.
+func header(data []byte, key string) (string, bool) {
+	for _, line := range strings.Split(string(headers(data)), "\n") {
+		if strings.HasPrefix(line, key+" ") {
+			return strings.TrimPrefix(line, key+" "), true
+		}
+	}
+	return "", false
+}
+
+// Files returns the files in the tree of the commit h, in the order of a
+// walk of the directories of a checkout.
+func (r *Repository) Files(h Hash) ([]File, error) {
+	c, err := r.Commit(h)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	if err := r.walkTree(c.Tree, "", &files); err != nil {
+		return nil, err
+	}
+	// Names are compared within each directory, as if "/" sorted first.
+	sort.Slice(files, func(i, j int) bool {
+		return strings.ReplaceAll(files[i].Path, "/", "\x00") < strings.ReplaceAll(files[j].Path, "/", "\x00")
+	})
+	return files, nil
+}
+
+// walkTree appends the files of the tree h, whose path is prefix, to files.
+func (r *Repository) walkTree(h Hash, prefix string, files *[]File) error {
+	typ, data, err := r.object(h)
+	if err != nil {
+		return err
+	}
+	if typ != "tree" {
+		return fmt.Errorf("%s is a %s, not a tree", h, typ)
+	}
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || len(data) < nul+21 {
+			return fmt.Errorf("invalid tree %s", h)
+		}
+		var mode int
+		if _, err := fmt.Sscanf(string(data[:space]), "%o", &mode); err != nil {
+			return fmt.Errorf("invalid tree %s", h)
+		}
+		name := prefix + string(data[space+1:nul])
+		var entry Hash
+		copy(entry[:], data[nul+1:nul+21])
+		data = data[nul+21:]
+
+		if mode == modeTree {
+			if err := r.walkTree(entry, name+"/", files); err != nil {
+				return err
+			}
+			continue
+		}
+		*files = append(*files, File{Path: name, Mode: mode, Hash: entry})
+	}
+	return nil
+}
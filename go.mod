@@ -1,5 +1,14 @@
 module textractor
 
-go 1.16
+go 1.18
 
-require github.com/spf13/pflag v1.0.5 
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.28.0 // indirect
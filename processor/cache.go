@@ -0,0 +1,291 @@
+package processor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"textractor/archive"
+	"textractor/cache"
+	"textractor/config"
+	"textractor/extractor"
+)
+
+// cacheVersion changes when the text produced for a file, or what is kept
+// with it, changes for the same settings, so that older caches are
+// discarded.
+const cacheVersion = 3
+
+// cached is what the cache keeps about a file besides its text.
+type cached struct {
+	Skipped   bool           `json:"skipped,omitempty"`   // no text is written for the file
+	Duplicate bool           `json:"duplicate,omitempty"` // the file duplicated another one, so its text is not cached
+	Streamed  bool           `json:"streamed,omitempty"`  // the text is written in blocks
+	Header    int            `json:"header,omitempty"`    // length of the header of the text
+	Parts     []int          `json:"parts,omitempty"`     // lengths of the parts of the text
+	PII       map[string]int `json:"pii,omitempty"`       // personal data counts for the report
+	Signature []uint64       `json:"signature,omitempty"` // signature for near duplicate detection
+	Truncated string         `json:"truncated,omitempty"` // text dropped by --truncate-file-at, such as "120 lines"
+}
+
+// cacheFile is the cache state of a file being extracted.
+type cacheFile struct {
+	sum    [32]byte // hash of the content
+	record cached
+	object *bufio.Writer // receives the text of the file
+}
+
+// cacheKey returns a fingerprint of the settings that affect the text of
+// each file. Settings that only select files, or act on the text after it
+// is cached, are left out. since is the commit files are diffed against,
+// if any.
+func cacheKey(cfg *config.Config, since string) (string, error) {
+	c := *cfg
+	c.InputDir, c.OutputFile, c.ConfigFile, c.CacheDir, c.PIIReport = "", "", "", "", ""
+	c.Since, c.GitRev, c.Watch = "", "", false
+	c.IgnoredExts, c.IncludedExts, c.IncludedDirs, c.ExcludedDirs = nil, nil, nil, nil
+	c.MaxWordsPerFile, c.MaxFileSize = 0, 0
+	c.Dedup, c.DedupNear = "", 0
+	c.Archives, c.ArchiveDepth, c.ArchiveMaxSize = false, 0, 0
+
+	data, err := json.Marshal(struct {
+		Version int
+		Config  config.Config
+		Since   string
+	}{cacheVersion, c, since})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheState returns the state stored in the cache under name, or nil.
+func (r *run) cacheState(name string) json.RawMessage {
+	if r.cache == nil {
+		return nil
+	}
+	return r.cache.State(name)
+}
+
+// processCached appends the text of f to the output from the cache when it
+// holds the text of the current content of f, and otherwise extracts it and
+// adds it to the cache.
+func (r *run) processCached(f *extractor.File, size int64, modTime time.Time) error {
+	entry, sum, err := r.cache.Get(f.Path, size, modTime, f.Open)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		sum = entry.Sum
+		ok, err := r.replay(f, entry)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.stats.CacheHits++
+			return nil
+		}
+	}
+	r.stats.CacheMisses++
+
+	file := &cacheFile{}
+	if _, err := hex.Decode(file.sum[:], []byte(sum)); err != nil {
+		return err
+	}
+	object, err := r.cache.Create(f.Path)
+	if err != nil {
+		return err
+	}
+	file.object = bufio.NewWriter(object)
+	r.file = file
+	err = r.extractContent(f)
+	r.file = nil
+	if flushErr := file.object.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := object.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(file.record)
+	if err != nil {
+		return err
+	}
+	r.cache.Put(f.Path, &cache.Entry{Size: size, ModTime: modTime, Sum: sum, Data: data})
+	return nil
+}
+
+// replay appends the cached text of f described by entry to the output, and
+// reports whether it could. It cannot when f was cached as a duplicate and
+// no longer is one, or when the entry lacks what near duplicate detection
+// needs.
+func (r *run) replay(f *extractor.File, entry *cache.Entry) (bool, error) {
+	var record cached
+	if err := json.Unmarshal(entry.Data, &record); err != nil {
+		return false, nil
+	}
+	if record.Skipped {
+		r.replayTruncated(record)
+		return true, nil
+	}
+	if r.dedup != nil {
+		if r.cfg.DedupNear > 0 && record.Signature == nil {
+			return false, nil
+		}
+		var sum [32]byte
+		if _, err := hex.Decode(sum[:], []byte(entry.Sum)); err != nil {
+			return false, nil
+		}
+		first := r.dedup.ExactSum(f.Path, sum)
+		if first == "" && r.cfg.DedupNear > 0 {
+			first = r.dedup.NearSignature(f.Path, record.Signature)
+		}
+		if first != "" {
+			return true, r.writeDuplicate(first)
+		}
+		if record.Duplicate {
+			return false, nil
+		}
+	}
+
+	if _, err := os.Stat(r.cache.Object(f.Path)); err != nil {
+		return false, nil
+	}
+	open := func() (io.ReadCloser, error) {
+		return r.cache.Open(f.Path)
+	}
+	if record.Streamed {
+		first := true
+		err := readBlocks(open, func(block string, _ bool) error {
+			if block == "" {
+				return nil
+			}
+			err := r.writeBlock(block, first)
+			first = false
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+	} else {
+		object, err := open()
+		if err != nil {
+			return false, err
+		}
+		data, err := ioutil.ReadAll(object)
+		object.Close()
+		if err != nil {
+			return false, err
+		}
+		result := &extractor.Result{Text: string(data)}
+		if !validParts(record, len(result.Text)) {
+			return false, nil
+		}
+		if len(record.Parts) > 0 {
+			pos := record.Header
+			result.Header = result.Text[:pos]
+			for _, n := range record.Parts {
+				result.Parts = append(result.Parts, result.Text[pos:pos+n])
+				pos += n
+			}
+		}
+		if err := r.write(result); err != nil {
+			return false, err
+		}
+	}
+	r.reportPII(f.Path, record.PII)
+	r.replayTruncated(record)
+	return true, nil
+}
+
+// replayTruncated counts the file described by record as truncated if it
+// was when it was cached.
+func (r *run) replayTruncated(record cached) {
+	if record.Truncated != "" {
+		r.stats.Truncated++
+	}
+}
+
+// validParts reports whether the parts of record add up to a text of n
+// bytes.
+func validParts(record cached, n int) bool {
+	if len(record.Parts) == 0 {
+		return true
+	}
+	total := record.Header
+	for _, part := range record.Parts {
+		total += part
+	}
+	return total == n
+}
+
+// recordSkipped records for the cache that no text is written for the
+// current file.
+func (r *run) recordSkipped() {
+	if r.file != nil {
+		r.file.record.Skipped = true
+	}
+}
+
+// recordTruncated counts the current file as truncated, with count the text
+// dropped, and records it for the cache.
+func (r *run) recordTruncated(count string) {
+	r.stats.Truncated++
+	if r.file != nil {
+		r.file.record.Truncated = count
+	}
+}
+
+// recordPII records the personal data counts of the current file for the
+// cache.
+func (r *run) recordPII(counts map[string]int) {
+	if r.file != nil && len(counts) > 0 {
+		r.file.record.PII = counts
+	}
+}
+
+// recordResult adds result, the text of the current file, to the cache.
+func (r *run) recordResult(result *extractor.Result) error {
+	if r.file == nil {
+		return nil
+	}
+	if len(result.Parts) > 0 {
+		r.file.record.Header = len(result.Header)
+		for _, part := range result.Parts {
+			r.file.record.Parts = append(r.file.record.Parts, len(part))
+		}
+	}
+	_, err := r.file.object.WriteString(result.Text)
+	return err
+}
+
+// recordBlock adds a block of the text of the current streamed file to the
+// cache.
+func (r *run) recordBlock(block string) error {
+	if r.file == nil {
+		return nil
+	}
+	r.file.record.Streamed = true
+	_, err := r.file.object.WriteString(block)
+	return err
+}
+
+// fileGone reports whether the file a cache entry was made for, or the
+// archive holding it, no longer exists.
+func fileGone(path string) bool {
+	if i := strings.Index(path, archive.Separator); i >= 0 {
+		path = path[:i]
+	}
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"strings"
+
+	"textractor/extractor"
+	"textractor/filehandler"
+)
+
+// splitContent returns the text of result cut into chunks of at most
+// maxWords words. Cuts are made between the parts of the result, and every
+// chunk starts with its header; a single part larger than the limit is cut
+// by splitWords into chunks of its own. Text without parts is cut between
+// words by splitWords.
+func splitContent(result *extractor.Result, maxWords int) []string {
+	if maxWords <= 0 || filehandler.CountWords(result.Text) <= maxWords {
+		return []string{result.Text}
+	}
+	if len(result.Parts) == 0 {
+		return splitWords(result.Text, maxWords)
+	}
+
+	headerWords := filehandler.CountWords(result.Header)
+	var chunks []string
+	var chunk strings.Builder
+	chunkWords := 0
+	chunkParts := 0
+
+	for _, part := range result.Parts {
+		partWords := filehandler.CountWords(part)
+		if chunkParts > 0 && headerWords+chunkWords+partWords > maxWords {
+			chunks = append(chunks, strings.TrimRight(result.Header+chunk.String(), "\n"))
+			chunk.Reset()
+			chunkWords = 0
+			chunkParts = 0
+		}
+		if headerWords+partWords > maxWords {
+			// At least one word of the part goes with each header, even
+			// when the header alone reaches the limit.
+			for _, piece := range splitWords(part, maxInt(maxWords-headerWords, 1)) {
+				chunks = append(chunks, strings.TrimRight(result.Header+piece, "\n"))
+			}
+			continue
+		}
+		chunk.WriteString(part)
+		chunkWords += partWords
+		chunkParts++
+	}
+	if chunkParts > 0 {
+		chunks = append(chunks, strings.TrimRight(result.Header+chunk.String(), "\n"))
+	}
+
+	return chunks
+}
+
+// splitWords cuts text into chunks of at most maxWords words, counted as
+// filehandler.CountWords does. Each chunk ends at the last line break before
+// the word that would exceed the limit, or right before that word when no
+// whole line fits. The chunks add up to text.
+func splitWords(text string, maxWords int) []string {
+	if maxWords <= 0 {
+		return []string{text}
+	}
+	var chunks []string
+	start, words, inWord := 0, 0, false
+	lineEnd := -1 // end of the last line of the chunk holding a word
+	for i := 0; i < len(text); i++ {
+		if !isWordByte(text[i]) {
+			inWord = false
+			if text[i] == '\n' && words > 0 {
+				lineEnd = i + 1
+			}
+			continue
+		}
+		if inWord {
+			continue
+		}
+		inWord = true
+		if words++; words <= maxWords {
+			continue
+		}
+		cut := i
+		if lineEnd > start {
+			cut = lineEnd
+		}
+		chunks = append(chunks, text[start:cut])
+		start, lineEnd = cut, -1
+		words = filehandler.CountWords(text[start:i]) + 1
+	}
+	return append(chunks, text[start:])
+}
+
+// isWordByte reports whether b is part of a word, as counted by
+// filehandler.CountWords. Words are ASCII, so bytes of multi-byte
+// characters never are.
+func isWordByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
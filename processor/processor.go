@@ -1,42 +1,209 @@
 package processor
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
+	"textractor/archive"
+	"textractor/cache"
 	"textractor/config"
+	"textractor/dedup"
+	"textractor/extractor"
 	"textractor/filehandler"
+	"textractor/git"
+	"textractor/redact"
 )
 
-var fileIndex int
+// run holds the state of one ProcessDirectory call.
+type run struct {
+	cfg         *config.Config
+	registry    *extractor.Registry
+	dedup       *dedup.Index // nil when duplicates are kept
+	transforms  []transform
+	filters     []*regexp.Regexp   // --skip-if-contains patterns
+	secrets     *redact.Redactor   // nil when secrets are neither redacted nor reported
+	pii         *redact.Redactor   // nil when personal data is kept
+	piiReport   []string           // personal data counts of each redacted file
+	pseudonyms  *redact.Pseudonyms // nil unless personal data is replaced by pseudonyms
+	outputFile  string             // output file currently written to
+	outputSize  int64              // bytes in the current output file
+	outputWords int                // words in the current output file
+	fileIndex   int                // number of the current output file
+	cache       *cache.Cache       // nil when no cache directory is set
+	file        *cacheFile         // cache state of the file being processed, nil when it is not cached
+	repo        *git.Repository    // repository of the input directory with --since or --git-rev
+	commit      git.Hash           // commit resolved from --since
+	changes     *git.Changes       // nil unless --since is set
+	base        *git.Hash          // blob the file being processed is diffed against, nil for no diff
+	watched     *watchedFile       // receives the output of the file being processed with --watch, nil otherwise
+	stats       Stats
+}
+
+// Stats counts what happened during a run.
+type Stats struct {
+	CacheHits   int     // files whose cached text was used
+	CacheMisses int     // files extracted and added to the cache
+	Truncated   int     // files cut by --truncate-file-at
+	Errors      []error // files, and rests of archives, skipped because they could not be extracted
+}
 
 func ProcessDirectory(cfg *config.Config) error {
-	inputDir := cfg.InputDir
-	outputFile := cfg.OutputFile
-	ignoredExts := cfg.IgnoredExts
-	onlyExts := cfg.IncludedExts
-	maxWordsPerFile := cfg.MaxWordsPerFile
-	fileIndex = 0
+	_, err := Process(cfg)
+	return err
+}
+
+// Process is ProcessDirectory also returning statistics about the run.
+func Process(cfg *config.Config) (*Stats, error) {
+	r := &run{
+		cfg:      cfg,
+		registry: extractor.NewRegistry(extractorOptions(cfg)),
+	}
+	if err := r.process(); err != nil {
+		return nil, err
+	}
+	return &r.stats, nil
+}
+
+func (r *run) process() error {
+	cfg := r.cfg
+	if err := r.setOutput(cfg.OutputFile); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	defer r.close()
 
-	// Create initial output file
-	outputFile = createNewOutputFile(outputFile, ".txt")
+	var err error
+	if cfg.GitRev != "" {
+		err = r.processRevision()
+	} else {
+		err = r.walk()
+	}
+	if err != nil {
+		return err
+	}
 
-	return filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+	if r.cache != nil {
+		if err := r.closeCache(); err != nil {
+			return err
+		}
+	}
+	if cfg.PIIReport != "" {
+		return writeReport(cfg.PIIReport, r.piiReport)
+	}
+	return nil
+}
+
+// open prepares what the files are processed with: the repository, the
+// cache, and the compiled filters and rules.
+func (r *run) open() error {
+	cfg := r.cfg
+	var since string
+	if cfg.Since != "" {
+		if err := r.openSince(); err != nil {
+			return err
+		}
+		if cfg.Diff != "" {
+			since = r.commit.String()
+		}
+	}
+	if cfg.CacheDir != "" {
+		key, err := cacheKey(cfg, since)
+		if err != nil {
+			return err
+		}
+		if r.cache, err = cache.Open(cfg.CacheDir, key); err != nil {
+			return err
+		}
+	}
+	if cfg.Dedup != "" {
+		r.dedup = dedup.New(cfg.DedupNear)
+	}
+	var err error
+	if r.transforms, err = compileTransforms(cfg.Transforms); err != nil {
+		return err
+	}
+	if r.filters, err = compileFilters(cfg.SkipIfContains); err != nil {
+		return err
+	}
+	if cfg.RedactSecrets || cfg.FailOnSecrets {
+		r.secrets = redact.New(redact.Secrets()...)
+	}
+	if len(cfg.PII) > 0 {
+		rules, err := redact.PII(cfg.PII...)
 		if err != nil {
 			return err
 		}
+		r.pii = redact.New(rules...)
+		if cfg.PIIPseudonyms {
+			r.pseudonyms = redact.NewPseudonyms()
+			if state := r.cacheState("pseudonyms"); state != nil {
+				if err := json.Unmarshal(state, r.pseudonyms); err != nil {
+					return err
+				}
+			}
+			r.pii.WithReplacement(r.pseudonyms.Replace)
+		}
+	}
+	return nil
+}
+
+// close releases the repository opened for --since.
+func (r *run) close() {
+	if r.repo != nil {
+		r.repo.Close()
+	}
+}
+
+// closeCache stores the state in the cache and closes it, forgetting the
+// files that no longer exist. Files left out of the run, such as those with
+// an ignored extension, stay cached for later runs. With --since the files
+// not changed since the commit are not looked up, and are all kept.
+func (r *run) closeCache() error {
+	if err := r.storeState(); err != nil {
+		return err
+	}
+	if r.cfg.Since != "" {
+		return r.cache.Flush()
+	}
+	return r.cache.Close(fileGone)
+}
+
+// storeState stores the pseudonyms in the cache so that later runs keep
+// replacing the same values with the same pseudonyms.
+func (r *run) storeState() error {
+	if r.pseudonyms == nil {
+		return nil
+	}
+	state, err := json.Marshal(r.pseudonyms)
+	if err != nil {
+		return err
+	}
+	r.cache.SetState("pseudonyms", state)
+	return nil
+}
+
+// walk extracts the files of the input directory.
+func (r *run) walk() error {
+	return filepath.Walk(r.cfg.InputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if r.changes != nil && info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
 
 		if info.Mode().IsRegular() {
-			err := processFile(
-				path,
-				ignoredExts,
-				onlyExts,
-				maxWordsPerFile,
-				&outputFile,
-			)
-			if err != nil {
+			if err := r.skipFailed(r.processFile(path, info)); err != nil {
 				return err
 			}
 		}
@@ -45,75 +212,426 @@ func ProcessDirectory(cfg *config.Config) error {
 	})
 }
 
-func processFile(path string, ignoredExts []string, onlyExts []string, maxWordsPerFile int, outputFile *string) error {
+// writeReport writes lines to the file at path, one per line.
+func writeReport(path string, lines []string) error {
+	var content string
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func (r *run) processFile(path string, info os.FileInfo) error {
 	fileExt := filepath.Ext(path)
 
-	if isFileIgnored(fileExt, ignoredExts) ||
-		!isFileIncluded(fileExt, onlyExts) {
+	if isFileIgnored(fileExt, r.cfg.IgnoredExts) {
+		return nil
+	}
+	r.base = nil
+	if r.changes != nil {
+		if changed, err := r.changed(path, info); err != nil || !changed {
+			return err
+		}
+	}
+	if r.cfg.Archives && archive.IsArchive(path) {
+		// The files inside a changed archive are extracted whole.
+		r.base = nil
+		return r.processArchive(path, nil)
+	}
+	if !isFileIncluded(fileExt, r.cfg.IncludedExts) || r.tooLarge(info.Size()) {
 		return nil
 	}
 
-	content, err := filehandler.ReadFileContent(path)
+	f, err := extractor.NewFile(path)
 	if err != nil {
 		return err
 	}
+	return r.processContent(f, info.Size(), info.ModTime())
+}
 
-	if shouldCreateNewFile(content, maxWordsPerFile, *outputFile, fileExt) {
-		fileIndex++
-		*outputFile = createNewOutputFile(*outputFile, fileExt)
+// processArchive extracts the files inside the archive at path that pass
+// the same extension rules as files on disk. The archive is read from data,
+// or from disk when data is nil.
+func (r *run) processArchive(path string, data []byte) error {
+	opts := archive.Options{MaxDepth: r.cfg.ArchiveDepth, MaxSize: r.cfg.ArchiveMaxSize}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = config.ARCHIVE_MAX_SIZE
 	}
 
-	return appendContentToFiles(content, maxWordsPerFile, outputFile)
+	fn := func(e archive.Entry) error {
+		fileExt := filepath.Ext(e.Path)
+		if isFileIgnored(fileExt, r.cfg.IgnoredExts) ||
+			!isFileIncluded(fileExt, r.cfg.IncludedExts) ||
+			r.tooLarge(int64(len(e.Data))) {
+			return nil
+		}
+
+		f, err := extractor.NewFileFromBytes(e.Path, e.Data)
+		if err != nil {
+			return err
+		}
+		return r.skipFailed(r.processContent(f, int64(len(e.Data)), time.Time{}))
+	}
+	var err error
+	if data != nil {
+		err = archive.WalkBytes(path, data, opts, fn)
+	} else {
+		err = archive.Walk(path, opts, fn)
+	}
+	if errors.Is(err, archive.ErrLimitExceeded) {
+		// An archive expanding past the limit, such as a zip bomb, is
+		// skipped from there on, keeping the entries already extracted.
+		r.stats.Errors = append(r.stats.Errors, err)
+		return nil
+	}
+	return err
 }
 
-func isFileIgnored(fileExt string, ignoredExts []string) bool {
-	return filehandler.IsIgnoredExtension(fileExt, ignoredExts)
+// skipFailed returns err, unless it reports that an extractor could not
+// extract a file: that file is skipped, and the error kept in the stats, so
+// that one malformed file does not stop the run.
+func (r *run) skipFailed(err error) error {
+	var extractErr *extractor.ExtractError
+	if errors.As(err, &extractErr) {
+		r.stats.Errors = append(r.stats.Errors, err)
+		return nil
+	}
+	return err
 }
 
-func isFileIncluded(fileExt string, onlyExts []string) bool {
-	return len(onlyExts) == 0 || filehandler.Contains(onlyExts, fileExt)
+// tooLarge reports whether a file of the given size exceeds --max-file-size.
+func (r *run) tooLarge(size int64) bool {
+	return r.cfg.MaxFileSize > 0 && size > r.cfg.MaxFileSize
 }
 
-func shouldCreateNewFile(content string, maxWordsPerFile int, outputFile, fileExt string) bool {
-	wordCountExistingFile := 0
-	if contentExisting, err := filehandler.ReadFileContent(outputFile); err == nil {
-		wordCountExistingFile = filehandler.CountWords(contentExisting)
+// processContent appends the text of f, of the given size and modification
+// time, to the output, from the cache when it holds it.
+func (r *run) processContent(f *extractor.File, size int64, modTime time.Time) error {
+	if r.cache == nil {
+		return r.extractContent(f)
 	}
-	wordCountNewContent := filehandler.CountWords(content)
-	return (wordCountExistingFile + wordCountNewContent) > maxWordsPerFile
+	return r.processCached(f, size, modTime)
 }
 
-func hasDifferentExtension(outputFile, fileExt string) bool {
-	return filepath.Ext(outputFile) != fileExt
+// extractContent extracts the text of f and appends it to the output,
+// continuing in new output files when it has to be split. Text that the
+// registry can stream is processed by processStream, unless it is diffed.
+func (r *run) extractContent(f *extractor.File) error {
+	if r.base == nil {
+		rc, err := r.registry.Stream(f)
+		if err != nil {
+			return err
+		}
+		if rc != nil {
+			return r.processStream(f, rc)
+		}
+	}
+
+	result, err := r.registry.Extract(f)
+	if err != nil {
+		return err
+	}
+	if r.base != nil {
+		if err := r.addDiff(f, result); err != nil {
+			return err
+		}
+	}
+	if result.Text == "" || skipContent(result.Text, r.filters) {
+		r.recordSkipped()
+		return nil
+	}
+	if r.dedup != nil {
+		first, err := r.duplicateOf(f, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(result.Text)), nil
+		})
+		if err != nil {
+			return err
+		}
+		if first != "" {
+			return r.writeDuplicate(first)
+		}
+	}
+	if r.cfg.TruncateAt.N > 0 {
+		truncateResult(result, r.cfg.TruncateAt, r.cfg.TruncateTail)
+		if count := result.Metadata["truncated"]; count != "" {
+			r.recordTruncated(count)
+		}
+	}
+	rel := relativePath(r.cfg.InputDir, f.Path)
+	for i := range r.transforms {
+		if r.transforms[i].matches(rel) {
+			applyTransform(r.transforms[i].re, r.transforms[i].replace, result)
+		}
+	}
+	if result.Text == "" {
+		r.recordSkipped()
+		return nil
+	}
+	if r.secrets != nil {
+		if err := r.redactSecrets(f.Path, result); err != nil {
+			return err
+		}
+	}
+	if r.pii != nil {
+		counts := map[string]int{}
+		r.redactPII(result, counts)
+		r.reportPII(f.Path, counts)
+		r.recordPII(counts)
+	}
+
+	if err := r.recordResult(result); err != nil {
+		return err
+	}
+	return r.write(result)
 }
 
-func createNewOutputFile(outputFile, fileExt string) string {
-	fileIndex++
-	newOutputFile := fmt.Sprintf("%s_%d%s", outputFile, fileIndex, fileExt)
-	_ = filehandler.CreateOutputFile(newOutputFile)
-	return newOutputFile
+// writeDuplicate writes the reference to first, the file that the current
+// file duplicates, unless duplicates are skipped.
+func (r *run) writeDuplicate(first string) error {
+	if r.file != nil {
+		r.file.record.Duplicate = true
+	}
+	if r.cfg.Dedup == "skip" {
+		return nil
+	}
+	return r.write(&extractor.Result{Text: "[same as " + first + "]"})
 }
 
-func appendContentToFiles(content string, maxWordsPerFile int, outputFile *string) error {
-	words := strings.Fields(content)
+// write appends the text of result to the output, continuing in new output
+// files when it has to be split.
+func (r *run) write(result *extractor.Result) error {
+	for i, content := range splitContent(result, r.cfg.MaxWordsPerFile) {
+		if err := r.emit(content, filehandler.CountWords(content), true, i > 0); err != nil {
+			return err
+		}
+	}
 
-	for i := 0; i < len(words); i += maxWordsPerFile {
-		end := i + maxWordsPerFile
+	return nil
+}
 
-		if end > len(words) {
-			end = len(words)
+// emit appends content holding words to the output, in a new output file
+// with newFile or when the current one is full. With separate, it is
+// separated from any content already written there. With --watch, the
+// content is recorded for the file being processed instead.
+func (r *run) emit(content string, words int, separate, newFile bool) error {
+	if r.watched != nil {
+		return r.watched.write(content, words, separate, newFile)
+	}
+	if newFile || r.full(words) {
+		if err := r.setOutput(r.nextOutputFile()); err != nil {
+			return err
 		}
+		separate = true
+	}
+	return r.append(content, words, separate)
+}
 
-		err := filehandler.AppendToOutputFile(*outputFile, strings.Join(words[i:end], " ")) // Modified this line
+// setOutput makes name the current output file, counting the words it
+// already holds.
+func (r *run) setOutput(name string) error {
+	r.outputFile, r.outputSize, r.outputWords = name, 0, 0
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return err
+	}
+	r.outputSize = info.Size()
+	r.outputWords, err = filehandler.CountWordsReader(file)
+	return err
+}
+
+// full reports whether adding words to the current output file would exceed
+// --max-words-per-file. An empty output file is never full.
+func (r *run) full(words int) bool {
+	return r.outputWords > 0 && r.outputWords+words > r.cfg.MaxWordsPerFile
+}
+
+// append appends content holding words to the current output file. With
+// separate, it is separated from any content already written there.
+func (r *run) append(content string, words int, separate bool) error {
+	if separate && r.outputSize > 0 {
+		content = "\n" + content
+	}
+	if err := filehandler.AppendToOutputFile(r.outputFile, content); err != nil {
+		return err
+	}
+	r.outputSize += int64(len(content))
+	r.outputWords += words
+	return nil
+}
+
+// duplicateOf returns the path of an earlier file with the same content as
+// f, or with text similar to the text of f returned by open when near
+// duplicates are detected, or "". f itself is not reported, as it may have
+// been indexed already from the cache.
+func (r *run) duplicateOf(f *extractor.File, open opener) (string, error) {
+	var first string
+	if r.file != nil {
+		first = r.dedup.ExactSum(f.Path, r.file.sum)
+	} else {
+		rc, err := f.Open()
 		if err != nil {
-			return err
+			return "", err
 		}
-
-		if end < len(words) && len(words[end:end+maxWordsPerFile]) > maxWordsPerFile {
-			fileIndex++
-			*outputFile = createNewOutputFile(*outputFile, filepath.Ext(*outputFile))
+		first, err = r.dedup.Exact(f.Path, rc)
+		rc.Close()
+		if err != nil {
+			return "", err
 		}
 	}
+	if first == f.Path {
+		first = ""
+	}
+	if first != "" || r.cfg.DedupNear <= 0 {
+		return first, nil
+	}
+
+	rc, err := open()
+	if err != nil {
+		return "", err
+	}
+	sig, err := dedup.Signature(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+	if r.file != nil {
+		r.file.record.Signature = sig
+	}
+	if first = r.dedup.NearSignature(f.Path, sig); first == f.Path {
+		first = ""
+	}
+	return first, nil
+}
+
+// redactSecrets replaces the secrets in result with placeholders, or
+// reports them as an error with --fail-on-secrets.
+func (r *run) redactSecrets(path string, result *extractor.Result) error {
+	if r.cfg.FailOnSecrets {
+		return secretsError(path, r.secrets.Find(result.Text))
+	}
 
+	redactResult(r.secrets, result)
 	return nil
 }
+
+// secretsError returns the error reporting the secrets found in the file at
+// path, or nil if there are none.
+func secretsError(path string, findings []redact.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	locations := make([]string, len(findings))
+	for i, f := range findings {
+		locations[i] = fmt.Sprintf("line %d (%s)", f.Line, f.Rule)
+	}
+	return fmt.Errorf("secrets found in %s: %s", path, strings.Join(locations, ", "))
+}
+
+// redactPII replaces the personal data in result and adds the number of
+// matches of each kind to counts.
+func (r *run) redactPII(result *extractor.Result, counts map[string]int) {
+	for _, f := range redactResult(r.pii, result) {
+		counts[f.Rule]++
+	}
+}
+
+// reportPII records the personal data counts of the file at path for the
+// report.
+func (r *run) reportPII(path string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	var kinds []string
+	for _, kind := range redact.PIIKinds {
+		if counts[kind] > 0 {
+			kinds = append(kinds, fmt.Sprintf("%s %d", kind, counts[kind]))
+		}
+	}
+	line := path + ": " + strings.Join(kinds, ", ")
+	if r.watched != nil {
+		r.watched.report = line
+		return
+	}
+	r.piiReport = append(r.piiReport, line)
+}
+
+// redactResult replaces the matches of rd in result and returns them. The
+// header and parts are redacted separately so that they still add up to the
+// text.
+func redactResult(rd *redact.Redactor, result *extractor.Result) []redact.Finding {
+	var findings []redact.Finding
+	if len(result.Parts) == 0 {
+		result.Text, findings = rd.Redact(result.Text)
+		return findings
+	}
+
+	result.Header, findings = rd.Redact(result.Header)
+	for i, part := range result.Parts {
+		var partFindings []redact.Finding
+		result.Parts[i], partFindings = rd.Redact(part)
+		findings = append(findings, partFindings...)
+	}
+	result.Text = result.Header + strings.Join(result.Parts, "")
+	return findings
+}
+
+// extractorOptions returns the extractor settings from the configuration.
+func extractorOptions(cfg *config.Config) extractor.Options {
+	opts := extractor.Options{
+		HTML:     extractor.HTMLOptions{KeepLinks: cfg.HTMLKeepLinks, Raw: cfg.HTMLRaw},
+		Notebook: extractor.NotebookOptions{Outputs: cfg.NotebookOutputs},
+		Structured: extractor.StructuredOptions{
+			Mode:          cfg.StructuredMode,
+			StringsOnly:   cfg.StructuredText,
+			MaxArrayItems: cfg.MaxArrayItems,
+		},
+		Tabular:  extractor.TabularOptions{Records: cfg.CSVRecords},
+		Subtitle: extractor.SubtitleOptions{TimestampInterval: cfg.SubtitleMarks},
+		Prose:    extractor.ProseOptions{Extensions: cfg.ProseExts},
+		Comments: extractor.CommentOptions{Only: cfg.CommentsOnly, Strip: cfg.StripComments},
+	}
+	for _, c := range cfg.Commands {
+		opts.Commands = append(opts.Commands, extractor.Command{
+			Extensions: c.Extensions,
+			MIMETypes:  c.MIMETypes,
+			Args:       c.Args,
+			Timeout:    c.Timeout.Duration,
+			OnError:    c.OnError,
+		})
+	}
+	return opts
+}
+
+func isFileIgnored(fileExt string, ignoredExts []string) bool {
+	return filehandler.IsIgnoredExtension(fileExt, ignoredExts)
+}
+
+func isFileIncluded(fileExt string, onlyExts []string) bool {
+	return len(onlyExts) == 0 || filehandler.Contains(onlyExts, fileExt)
+}
+
+// nextOutputFile returns the name of the next output file.
+func (r *run) nextOutputFile() string {
+	r.fileIndex++
+	return outputFileName(r.cfg.OutputFile, r.fileIndex)
+}
+
+// outputFileName returns the name of the output file numbered n after the
+// configured output file name (output.txt, output_1.txt, ...).
+func outputFileName(name string, n int) string {
+	if n == 0 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"textractor/archive"
+	"textractor/extractor"
+	"textractor/git"
+)
+
+// processRevision extracts the files of the --git-rev commit from the
+// repository of the input directory, in the order a directory walk of a
+// checkout would. When the input directory is below the root of the work
+// tree, only the files under it are extracted. Files are named as in the
+// input directory.
+func (r *run) processRevision() error {
+	repo, err := git.Discover(r.cfg.InputDir)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	r.repo = repo
+	commit, err := repo.Resolve(r.cfg.GitRev)
+	if err != nil {
+		return err
+	}
+	files, err := repo.Files(commit)
+	if err != nil {
+		return err
+	}
+
+	var prefix string
+	if repo.WorkTree() != "" {
+		if rel := relativePath(repo.WorkTree(), absPath(r.cfg.InputDir)); rel != "." {
+			prefix = rel + "/"
+		}
+	}
+	for _, file := range files {
+		if !file.Regular() || !strings.HasPrefix(file.Path, prefix) {
+			continue
+		}
+		path := filepath.Join(r.cfg.InputDir, filepath.FromSlash(strings.TrimPrefix(file.Path, prefix)))
+		if err := r.skipFailed(r.processBlob(path, file.Hash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processBlob extracts the file at path from the blob h, with the same
+// rules as processFile.
+func (r *run) processBlob(path string, h git.Hash) error {
+	fileExt := filepath.Ext(path)
+
+	if isFileIgnored(fileExt, r.cfg.IgnoredExts) {
+		return nil
+	}
+	isArchive := r.cfg.Archives && archive.IsArchive(path)
+	if !isArchive {
+		if !isFileIncluded(fileExt, r.cfg.IncludedExts) {
+			return nil
+		}
+		size, err := r.repo.Size(h)
+		if err != nil {
+			return err
+		}
+		if r.tooLarge(size) {
+			return nil
+		}
+	}
+
+	data, err := r.repo.Blob(h)
+	if err != nil {
+		return err
+	}
+	if isArchive {
+		return r.processArchive(path, data)
+	}
+	f, err := extractor.NewFileFromBytes(path, data)
+	if err != nil {
+		return err
+	}
+	return r.processContent(f, int64(len(data)), time.Time{})
+}
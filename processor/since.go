@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+
+	"textractor/extractor"
+	"textractor/git"
+)
+
+// openSince resolves the --since revision in the repository holding the
+// input directory and prepares the comparison of its work tree with it.
+func (r *run) openSince() error {
+	repo, err := git.Discover(r.cfg.InputDir)
+	if err != nil {
+		return err
+	}
+	commit, err := repo.Resolve(r.cfg.Since)
+	if err != nil {
+		repo.Close()
+		return err
+	}
+	changes, err := repo.Compare(commit)
+	if err != nil {
+		repo.Close()
+		return err
+	}
+	r.repo, r.commit, r.changes = repo, commit, changes
+	return nil
+}
+
+// changed reports whether the file at path was added or modified since the
+// --since revision, and sets the blob it is diffed against.
+func (r *run) changed(path string, info os.FileInfo) (bool, error) {
+	old, changed, err := r.changes.Check(path, info)
+	if err != nil || !changed {
+		return false, err
+	}
+	if r.cfg.Diff != "" {
+		r.base = &old
+	}
+	return true, nil
+}
+
+// addDiff adds the diff of the text of f since the --since revision to
+// result, replacing its text with --diff only. The previous text is
+// extracted from the blob in r.base the same way.
+func (r *run) addDiff(f *extractor.File, result *extractor.Result) error {
+	rel := relativePath(r.repo.WorkTree(), absPath(f.Path))
+	var oldText, oldName string
+	if !r.base.IsZero() {
+		data, err := r.repo.Blob(*r.base)
+		if err != nil {
+			return err
+		}
+		old, err := extractor.NewFileFromBytes(f.Path, data)
+		if err != nil {
+			return err
+		}
+		previous, err := r.registry.Extract(old)
+		if err != nil {
+			return err
+		}
+		oldText, oldName = previous.Text, "a/"+rel
+	}
+	diff := git.Diff(oldName, "b/"+rel, oldText, result.Text)
+
+	if r.cfg.Diff == "only" {
+		result.Text, result.Header, result.Parts = diff, "", nil
+		return nil
+	}
+	if diff == "" {
+		return nil
+	}
+	if result.Text != "" && result.Text[len(result.Text)-1] != '\n' {
+		diff = "\n" + diff
+	}
+	result.Text += diff
+	if len(result.Parts) > 0 {
+		result.Parts = append(result.Parts, diff)
+	}
+	return nil
+}
+
+// absPath returns the absolute form of path, or path when it has none.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
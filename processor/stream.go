@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"textractor/extractor"
+	"textractor/filehandler"
+	"textractor/redact"
+)
+
+// blockSize is the amount of text of a streamed file processed at once.
+// Blocks end at a line break unless a line is longer than blockSize.
+const blockSize = 1 << 20
+
+// errStop stops readBlocks early without reporting an error.
+var errStop = errors.New("stop reading blocks")
+
+// opener returns a new stream of the text of a file.
+type opener func() (io.ReadCloser, error)
+
+// readBlocks reads the stream returned by open in blocks of whole lines and
+// passes them to fn, final being set for the last block, which may be empty.
+func readBlocks(open opener, fn func(block string, final bool) error) error {
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	br := bufio.NewReaderSize(rc, blockSize)
+	var block []byte
+	for {
+		line, err := br.ReadSlice('\n')
+		block = append(block, line...)
+		if err == io.EOF {
+			err = fn(string(block), true)
+			if err == errStop {
+				return nil
+			}
+			return err
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return err
+		}
+		if len(block) >= blockSize {
+			if err := fn(string(block), false); err != nil {
+				if err == errStop {
+					return nil
+				}
+				return err
+			}
+			block = block[:0]
+		}
+	}
+}
+
+// processStream appends the text of f, streamed by the registry from rc, to
+// the output, in bounded memory: the text is truncated, transformed,
+// redacted, counted and written one block at a time. The steps that need
+// the whole text before anything is written, --skip-if-contains, near
+// duplicates, truncation and --fail-on-secrets, read it again in a pass of
+// their own. Transforms and redaction miss matches spanning two blocks.
+func (r *run) processStream(f *extractor.File, rc io.ReadCloser) error {
+	br := bufio.NewReaderSize(rc, blockSize)
+	if _, err := br.Peek(1); err != nil {
+		rc.Close()
+		if err == io.EOF {
+			r.recordSkipped()
+			return nil
+		}
+		return err
+	}
+	first := io.ReadCloser(struct {
+		io.Reader
+		io.Closer
+	}{br, rc})
+	open := func() (io.ReadCloser, error) {
+		if first != nil {
+			rc := first
+			first = nil
+			return rc, nil
+		}
+		return r.registry.Stream(f)
+	}
+	defer func() {
+		if first != nil {
+			first.Close()
+		}
+	}()
+
+	if len(r.filters) > 0 {
+		skip := false
+		err := readBlocks(open, func(block string, _ bool) error {
+			if skipContent(block, r.filters) {
+				skip = true
+				return errStop
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if skip {
+			r.recordSkipped()
+			return nil
+		}
+	}
+	if r.dedup != nil {
+		dup, err := r.duplicateOf(f, open)
+		if err != nil {
+			return err
+		}
+		if dup != "" {
+			return r.writeDuplicate(dup)
+		}
+	}
+
+	var total int64
+	if r.cfg.TruncateAt.N > 0 {
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		total, err = countUnits(rc, r.cfg.TruncateAt.Unit)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	// blocks passes the truncated and transformed blocks of the text to
+	// fn, with the number of their first line.
+	// The truncation of the last pass is kept in t.
+	rel := relativePath(r.cfg.InputDir, f.Path)
+	var t *truncation
+	blocks := func(fn func(result *extractor.Result, line int) error) error {
+		t = nil
+		if r.cfg.TruncateAt.N > 0 {
+			t = newTruncation(r.cfg.TruncateAt, r.cfg.TruncateTail, total)
+		}
+		line := 1
+		return readBlocks(open, func(block string, final bool) error {
+			if t != nil {
+				block = t.filter(block, final)
+			}
+			result := &extractor.Result{Text: block}
+			for i := range r.transforms {
+				if r.transforms[i].matches(rel) {
+					applyTransform(r.transforms[i].re, r.transforms[i].replace, result)
+				}
+			}
+			if result.Text == "" {
+				return nil
+			}
+			err := fn(result, line)
+			line += strings.Count(result.Text, "\n")
+			return err
+		})
+	}
+
+	if r.secrets != nil && r.cfg.FailOnSecrets {
+		var findings []redact.Finding
+		err := blocks(func(result *extractor.Result, line int) error {
+			for _, finding := range r.secrets.Find(result.Text) {
+				finding.Line += line - 1
+				findings = append(findings, finding)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := secretsError(f.Path, findings); err != nil {
+			return err
+		}
+	}
+
+	piiCounts := map[string]int{}
+	written := false
+	err := blocks(func(result *extractor.Result, _ int) error {
+		if r.secrets != nil && !r.cfg.FailOnSecrets {
+			redactResult(r.secrets, result)
+		}
+		if r.pii != nil {
+			r.redactPII(result, piiCounts)
+		}
+		if err := r.recordBlock(result.Text); err != nil {
+			return err
+		}
+		err := r.writeBlock(result.Text, !written)
+		written = true
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if t != nil && t.cut {
+		r.recordTruncated(t.count())
+	}
+	if !written {
+		r.recordSkipped()
+	}
+	r.reportPII(f.Path, piiCounts)
+	r.recordPII(piiCounts)
+	return nil
+}
+
+// writeBlock appends a block of the text of a streamed file to the output,
+// continuing in a new output file when the current one is full, and cutting
+// blocks longer than --max-words-per-file. first marks the first block of
+// the file, separated from the content already written.
+func (r *run) writeBlock(block string, first bool) error {
+	for i, chunk := range splitWords(block, r.cfg.MaxWordsPerFile) {
+		if err := r.emit(chunk, filehandler.CountWords(chunk), first && i == 0, i > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
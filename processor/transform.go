@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"textractor/config"
+	"textractor/extractor"
+)
+
+// transform is a configured replacement with its compiled file globs.
+type transform struct {
+	re      *regexp.Regexp
+	replace string
+	files   []*regexp.Regexp // nil to transform every file
+}
+
+// compileTransforms compiles the configured transforms and their globs.
+func compileTransforms(cfgTransforms []config.Transform) ([]transform, error) {
+	transforms := make([]transform, 0, len(cfgTransforms))
+	for _, t := range cfgTransforms {
+		re := t.Regexp
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(t.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid transform pattern: %s", err)
+			}
+		}
+		tr := transform{re: re, replace: t.Replace}
+		for _, glob := range t.Files {
+			tr.files = append(tr.files, globRegexp(glob))
+		}
+		transforms = append(transforms, tr)
+	}
+	return transforms, nil
+}
+
+// compileFilters compiles the --skip-if-contains patterns.
+func compileFilters(patterns []string) ([]*regexp.Regexp, error) {
+	filters := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --skip-if-contains pattern: %s", err)
+		}
+		filters = append(filters, re)
+	}
+	return filters, nil
+}
+
+// globRegexp converts a glob to a regular expression matching slash-separated
+// paths. "*" and "?" do not match "/", "**" matches across directories, and
+// a glob without "/" matches the base name of a path.
+func globRegexp(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	if strings.Contains(glob, "/") {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("(?:^|/)")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			sb.WriteString("[^/]*")
+		case glob[i] == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// matches reports whether t applies to the file at rel, a slash-separated
+// path relative to the input directory.
+func (t *transform) matches(rel string) bool {
+	if len(t.files) == 0 {
+		return true
+	}
+	for _, re := range t.files {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// relativePath returns the slash-separated path of name relative to dir.
+func relativePath(dir, name string) string {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil {
+		rel = name
+	}
+	return path.Clean(filepath.ToSlash(rel))
+}
+
+// skipContent reports whether text matches one of the filters.
+func skipContent(text string, filters []*regexp.Regexp) bool {
+	for _, re := range filters {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTransform replaces the matches of re in the text of result. The
+// boundaries between its header and parts move with the surrounding text; a
+// boundary inside a match moves to the end of its replacement.
+func applyTransform(re *regexp.Regexp, replace string, result *extractor.Result) {
+	text := result.Text
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	var cuts []int
+	if len(result.Parts) > 0 {
+		pos := len(result.Header)
+		cuts = append(cuts, pos)
+		for _, part := range result.Parts[:len(result.Parts)-1] {
+			pos += len(part)
+			cuts = append(cuts, pos)
+		}
+	}
+
+	var out []byte
+	newCuts := make([]int, len(cuts))
+	ci, last := 0, 0
+	for _, m := range matches {
+		for ; ci < len(cuts) && cuts[ci] <= m[0]; ci++ {
+			newCuts[ci] = len(out) + cuts[ci] - last
+		}
+		out = append(out, text[last:m[0]]...)
+		out = re.ExpandString(out, replace, text, m)
+		for ; ci < len(cuts) && cuts[ci] < m[1]; ci++ {
+			newCuts[ci] = len(out)
+		}
+		last = m[1]
+	}
+	for ; ci < len(cuts); ci++ {
+		newCuts[ci] = len(out) + cuts[ci] - last
+	}
+	out = append(out, text[last:]...)
+
+	result.Text = string(out)
+	if len(cuts) == 0 {
+		return
+	}
+	result.Header = result.Text[:newCuts[0]]
+	for i := range result.Parts {
+		end := len(result.Text)
+		if i+1 < len(newCuts) {
+			end = newCuts[i+1]
+		}
+		result.Parts[i] = result.Text[newCuts[i]:end]
+	}
+}
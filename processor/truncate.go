@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"textractor/config"
+	"textractor/extractor"
+)
+
+// truncation decides, character by character, which text of a file is kept
+// under a --truncate-file-at limit: the first head units and, with
+// --truncate-tail, the units from tailFrom to the end. Dropped text is
+// replaced with a "[... truncated N lines ...]" marker counting it in the
+// unit of the limit.
+type truncation struct {
+	unit     string
+	head     int64 // units kept at the start
+	tailFrom int64 // first unit kept at the end
+
+	offset  int64 // bytes walked
+	lines   int64 // line breaks walked
+	words   int64 // words started, including the current one
+	inWord  bool
+	dropped int64 // bytes dropped
+	cut     bool  // whether text has been dropped
+	marked  bool  // whether the marker has been written
+	last    rune  // last kept character
+}
+
+// newTruncation returns the truncation of a text of total units, or nil
+// when it fits in the limit.
+func newTruncation(limit config.Limit, tail bool, total int64) *truncation {
+	head, tailN := limit.N, int64(0)
+	if tail {
+		head, tailN = limit.N-limit.N/2, limit.N/2
+	}
+	if total <= head+tailN {
+		return nil
+	}
+	return &truncation{unit: limit.Unit, head: head, tailFrom: total - tailN}
+}
+
+// keep reports whether c, encoded in size bytes, is kept, and walks past it.
+func (t *truncation) keep(c rune, size int) bool {
+	var kept bool
+	switch t.unit {
+	case "lines":
+		kept = t.lines < t.head || t.lines >= t.tailFrom
+		if c == '\n' {
+			t.lines++
+		}
+	case "words":
+		if unicode.IsSpace(c) {
+			t.inWord = false
+			kept = t.words < t.head || t.words > t.tailFrom
+		} else {
+			if !t.inWord {
+				t.inWord = true
+				t.words++
+			}
+			kept = t.words <= t.head || t.words > t.tailFrom
+		}
+	default:
+		end := t.offset + int64(size)
+		kept = end <= t.head || t.offset >= t.tailFrom
+		if !kept {
+			t.dropped += int64(size)
+		}
+	}
+	t.offset += int64(size)
+	return kept
+}
+
+// count returns the amount of dropped text, such as "120 lines".
+func (t *truncation) count() string {
+	if t.unit == "lines" || t.unit == "words" {
+		return fmt.Sprintf("%d %s", t.tailFrom-t.head, t.unit)
+	}
+	return fmt.Sprintf("%d %s", t.dropped, t.unit)
+}
+
+// marker returns the text replacing the dropped text, on a line of its own
+// after the kept head.
+func (t *truncation) marker() string {
+	marker := "[... truncated " + t.count() + " ...]\n"
+	if t.last != 0 && t.last != '\n' {
+		marker = "\n" + marker
+	}
+	return marker
+}
+
+// filter returns the kept text of block, the next piece of the walked text,
+// with the marker in place of the dropped text. final marks the last block.
+func (t *truncation) filter(block string, final bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(block); {
+		c, size := utf8.DecodeRuneInString(block[i:])
+		if t.keep(c, size) {
+			if t.cut && !t.marked {
+				sb.WriteString(t.marker())
+				t.marked = true
+			}
+			sb.WriteString(block[i : i+size])
+			t.last = c
+		} else {
+			t.cut = true
+		}
+		i += size
+	}
+	if final && t.cut && !t.marked {
+		sb.WriteString(t.marker())
+		t.marked = true
+	}
+	return sb.String()
+}
+
+// countUnits returns the length of the text read from r in the given unit.
+func countUnits(r io.Reader, unit string) (int64, error) {
+	br := bufio.NewReaderSize(r, blockSize)
+	var n int64
+	switch unit {
+	case "lines":
+		var last byte
+		for {
+			chunk, err := br.ReadSlice('\n')
+			if len(chunk) > 0 {
+				last = chunk[len(chunk)-1]
+				if last == '\n' {
+					n++
+				}
+			}
+			if err == io.EOF {
+				if last != 0 && last != '\n' {
+					n++
+				}
+				return n, nil
+			}
+			if err != nil && err != bufio.ErrBufferFull {
+				return 0, err
+			}
+		}
+	case "words":
+		inWord := false
+		for {
+			c, _, err := br.ReadRune()
+			if err == io.EOF {
+				return n, nil
+			}
+			if err != nil {
+				return 0, err
+			}
+			if unicode.IsSpace(c) {
+				inWord = false
+			} else if !inWord {
+				inWord = true
+				n++
+			}
+		}
+	default:
+		return io.Copy(io.Discard, br)
+	}
+}
+
+// truncateResult applies the --truncate-file-at limit to result. The parts
+// keep their boundaries; those left empty are dropped. The truncation is
+// recorded in the metadata.
+func truncateResult(result *extractor.Result, limit config.Limit, tail bool) {
+	text := result.Text
+	total, _ := countUnits(strings.NewReader(text), limit.Unit)
+	t := newTruncation(limit, tail, total)
+	if t == nil {
+		return
+	}
+
+	headEnd, tailStart := -1, len(text)
+	for i := 0; i < len(text); {
+		c, size := utf8.DecodeRuneInString(text[i:])
+		kept := t.keep(c, size)
+		switch {
+		case !kept && headEnd < 0:
+			headEnd = i
+		case kept && headEnd >= 0:
+			tailStart = i
+			i = len(text)
+			continue
+		}
+		if kept {
+			t.last = c
+		}
+		i += size
+	}
+	marker := t.marker()
+
+	segments := append([]string{result.Header}, result.Parts...)
+	if len(result.Parts) == 0 {
+		segments = []string{text}
+	}
+	kept := make([]string, 0, len(segments))
+	start := 0
+	for _, segment := range segments {
+		end := start + len(segment)
+		var sb strings.Builder
+		if start < headEnd {
+			sb.WriteString(text[start:minInt(end, headEnd)])
+		}
+		if start <= headEnd && headEnd < end {
+			sb.WriteString(marker)
+		}
+		if end > tailStart {
+			sb.WriteString(text[maxInt(start, tailStart):end])
+		}
+		kept = append(kept, sb.String())
+		start = end
+	}
+
+	result.Text = strings.Join(kept, "")
+	if len(result.Parts) > 0 {
+		result.Header = kept[0]
+		result.Parts = result.Parts[:0]
+		for _, part := range kept[1:] {
+			if part != "" {
+				result.Parts = append(result.Parts, part)
+			}
+		}
+	}
+	if result.Metadata == nil {
+		result.Metadata = map[string]string{}
+	}
+	result.Metadata["truncated"] = t.count()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
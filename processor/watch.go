@@ -0,0 +1,623 @@
+package processor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"textractor/archive"
+	"textractor/config"
+	"textractor/dedup"
+	"textractor/extractor"
+)
+
+// watchDelay is how long the input directory must stay quiet after a change
+// before the output is updated, so that a burst of events, such as an editor
+// saving a file, is handled at once.
+const watchDelay = 200 * time.Millisecond
+
+// tempPrefix starts the names of the temporary files that replace output
+// files.
+const tempPrefix = ".textractor-"
+
+// Update describes how Watch brought the output up to date.
+type Update struct {
+	Files   []string // files extracted again or removed, all of them for the first update
+	Outputs []string // output files written or removed
+	Errors  []error  // files that could not be extracted, whose previous text is kept
+}
+
+// outputOp is a piece of text written to the output, recorded with --watch
+// so that the output can be laid out again when files change.
+type outputOp struct {
+	size     int64 // bytes of text in the spool file
+	words    int
+	separate bool // separated from the content already in the output file
+	newFile  bool // starts a new output file
+}
+
+// watchedFile is what a file contributes to the output and the report. The
+// text of its output is kept on disk, in a spool file, so that memory does
+// not grow with the text of the input directory.
+type watchedFile struct {
+	ops    []outputOp
+	spool  string // file holding the text of ops one after the other, "" when there are none
+	report string // line of the personal data report, "" for none
+
+	dir    string        // directory of the spool file
+	file   *os.File      // spool file being written while the file is extracted
+	writer *bufio.Writer // buffers writes to file
+}
+
+// write appends content, holding words, to the output of the file.
+func (f *watchedFile) write(content string, words int, separate, newFile bool) error {
+	if f.file == nil {
+		file, err := ioutil.TempFile(f.dir, "spool-*")
+		if err != nil {
+			return err
+		}
+		f.spool, f.file, f.writer = file.Name(), file, bufio.NewWriter(file)
+	}
+	if _, err := f.writer.WriteString(content); err != nil {
+		return err
+	}
+	f.ops = append(f.ops, outputOp{int64(len(content)), words, separate, newFile})
+	return nil
+}
+
+// finish closes the spool file once the file is extracted.
+func (f *watchedFile) finish() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.writer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file, f.writer = nil, nil
+	return err
+}
+
+// discard removes the spool file.
+func (f *watchedFile) discard() {
+	if f.spool != "" {
+		os.Remove(f.spool)
+	}
+}
+
+// watcher keeps the output of a run up to date with the input directory.
+type watcher struct {
+	r       *run
+	fsw     *fsnotify.Watcher
+	paths   []string // files of the input directory, in walk order
+	files   map[string]*watchedFile
+	dirs    map[string]bool // directories being watched
+	flushed bool            // whether the output has been written
+	sums    []outputSum     // content of each output file
+	report  outputSum       // content of the personal data report
+
+	outputPattern        *regexp.Regexp // names of the output files
+	outputDir            string
+	cacheDir, reportFile string
+	spoolDir             string // directory of the spool files
+}
+
+// outputSum identifies the content of an output file.
+type outputSum [sha256.Size]byte
+
+// Watch extracts the input directory like Process, then keeps the output up
+// to date as files change until stop is closed. Only the files that changed
+// are extracted again, and only the output files whose content changed are
+// replaced, each at once. Changes to the output files themselves, the
+// report and the cache are ignored. update is called after the output is
+// first written and after each update.
+func Watch(cfg *config.Config, stop <-chan struct{}, update func(Update)) error {
+	r := &run{
+		cfg:      cfg,
+		registry: extractor.NewRegistry(extractorOptions(cfg)),
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	defer r.close()
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	spoolDir, err := ioutil.TempDir("", "textractor-watch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(spoolDir)
+	w := newWatcher(r, fsw, spoolDir)
+
+	paths, err := w.add(cfg.InputDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := w.extract(path); err != nil {
+			return err
+		}
+	}
+	u := Update{Files: paths}
+	if err := w.flush(&u); err != nil {
+		return err
+	}
+	update(u)
+
+	pending := map[string]fsnotify.Op{}
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return w.closeCache()
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return w.closeCache()
+			}
+			if event.Op == fsnotify.Chmod || w.ignored(event.Name) {
+				continue
+			}
+			pending[event.Name] |= event.Op
+			quiet = time.After(watchDelay)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return w.closeCache()
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return err
+			}
+			// Events were lost: look at the whole input directory again.
+			pending[cfg.InputDir] |= fsnotify.Create
+			quiet = time.After(watchDelay)
+		case <-quiet:
+			u, err := w.update(pending)
+			if err != nil {
+				return err
+			}
+			update(u)
+			pending, quiet = map[string]fsnotify.Op{}, nil
+		}
+	}
+}
+
+func newWatcher(r *run, fsw *fsnotify.Watcher, spoolDir string) *watcher {
+	w := &watcher{
+		r:        r,
+		fsw:      fsw,
+		files:    map[string]*watchedFile{},
+		dirs:     map[string]bool{},
+		spoolDir: absPath(spoolDir),
+	}
+	output := absPath(r.cfg.OutputFile)
+	ext := filepath.Ext(output)
+	w.outputPattern = regexp.MustCompile("^" + regexp.QuoteMeta(strings.TrimSuffix(output, ext)) +
+		`(_[0-9]+)?` + regexp.QuoteMeta(ext) + "$")
+	w.outputDir = filepath.Dir(output)
+	if r.cfg.CacheDir != "" {
+		w.cacheDir = absPath(r.cfg.CacheDir)
+	}
+	if r.cfg.PIIReport != "" {
+		w.reportFile = absPath(r.cfg.PIIReport)
+	}
+	return w
+}
+
+// output reports whether path is written by the watcher: an output file,
+// the personal data report, a temporary file replacing one of them, or a
+// file of the cache or of the spool directory.
+func (w *watcher) output(path string) bool {
+	abs := absPath(path)
+	if inDir(abs, w.spoolDir) || (w.cacheDir != "" && inDir(abs, w.cacheDir)) {
+		return true
+	}
+	if abs == w.reportFile || w.outputPattern.MatchString(abs) {
+		return true
+	}
+	dir := filepath.Dir(abs)
+	return strings.HasPrefix(filepath.Base(abs), tempPrefix) &&
+		(dir == w.outputDir || (w.reportFile != "" && dir == filepath.Dir(w.reportFile)))
+}
+
+// inDir reports whether path is dir or below it.
+func inDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// ignored reports whether changes to path cannot affect the output: it is
+// written by the watcher, or it is not a directory and its extension
+// excludes it.
+func (w *watcher) ignored(path string) bool {
+	if w.output(path) {
+		return true
+	}
+	if w.dirs[path] {
+		return false
+	}
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		return false
+	}
+	if _, ok := w.files[path]; ok {
+		return false
+	}
+	ext := filepath.Ext(path)
+	if isFileIgnored(ext, w.r.cfg.IgnoredExts) {
+		return true
+	}
+	if w.r.cfg.Archives && archive.IsArchive(path) {
+		return false
+	}
+	return !isFileIncluded(ext, w.r.cfg.IncludedExts)
+}
+
+// add watches dir and the directories below it, and returns their files in
+// walk order.
+func (w *watcher) add(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Removed since; its own event follows.
+				return nil
+			}
+			return err
+		}
+		if w.output(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if w.r.changes != nil && info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !w.dirs[path] {
+				if err := w.fsw.Add(path); err != nil {
+					return err
+				}
+				w.dirs[path] = true
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// extract extracts the file at path again and records its output.
+func (w *watcher) extract(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		w.remove(path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	file := &watchedFile{dir: w.spoolDir}
+	w.r.watched = file
+	err = w.r.processFile(path, info)
+	w.r.watched = nil
+	if finishErr := file.finish(); err == nil {
+		err = finishErr
+	}
+	if err != nil {
+		file.discard()
+		return err
+	}
+	if old, ok := w.files[path]; ok {
+		old.discard()
+	} else {
+		i := sort.Search(len(w.paths), func(i int) bool { return !walkLess(w.paths[i], path) })
+		w.paths = append(w.paths, "")
+		copy(w.paths[i+1:], w.paths[i:])
+		w.paths[i] = path
+	}
+	w.files[path] = file
+	return nil
+}
+
+// remove forgets the file at path, or the files and directories below the
+// directory at path, and returns the files forgotten.
+func (w *watcher) remove(path string) []string {
+	prefix := path + string(filepath.Separator)
+	var removed []string
+	paths := w.paths[:0]
+	for _, p := range w.paths {
+		if p == path || strings.HasPrefix(p, prefix) {
+			removed = append(removed, p)
+			w.files[p].discard()
+			delete(w.files, p)
+		} else {
+			paths = append(paths, p)
+		}
+	}
+	w.paths = paths
+	for dir := range w.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			// The watch is gone with the directory, or follows it elsewhere.
+			_ = w.fsw.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	return removed
+}
+
+// update handles the pending events, extracting the changed files again,
+// and rewrites the output.
+func (w *watcher) update(pending map[string]fsnotify.Op) (Update, error) {
+	var u Update
+	changed := map[string]bool{}
+	for path, op := range pending {
+		info, err := os.Lstat(path)
+		switch {
+		case os.IsNotExist(err):
+			u.Files = append(u.Files, w.remove(path)...)
+		case err != nil:
+			u.Errors = append(u.Errors, err)
+		case info.IsDir():
+			if op&(fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			paths, err := w.add(path)
+			if err != nil {
+				return u, err
+			}
+			for _, p := range paths {
+				changed[p] = true
+			}
+		case info.Mode().IsRegular():
+			changed[path] = true
+		}
+	}
+	if w.r.dedup != nil && len(changed)+len(u.Files) > 0 {
+		// Whether a file duplicates another depends on the files before it.
+		w.r.dedup = dedup.New(w.r.cfg.DedupNear)
+		for _, path := range w.paths {
+			changed[path] = true
+		}
+	}
+
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return walkLess(paths[i], paths[j]) })
+	for _, path := range paths {
+		if err := w.extract(path); err != nil {
+			u.Errors = append(u.Errors, fmt.Errorf("%s: %v", path, err))
+		}
+	}
+	// The files skipped inside archives are reported too.
+	u.Errors = append(u.Errors, w.r.stats.Errors...)
+	w.r.stats.Errors = nil
+	u.Files = append(u.Files, paths...)
+	sort.Slice(u.Files, func(i, j int) bool { return walkLess(u.Files[i], u.Files[j]) })
+	return u, w.flush(&u)
+}
+
+// walkLess reports whether filepath.Walk visits the file at a before the
+// file at b.
+func walkLess(a, b string) bool {
+	sep := string(filepath.Separator)
+	return strings.Replace(a, sep, "\x00", -1) < strings.Replace(b, sep, "\x00", -1)
+}
+
+// flush rewrites the output files and the report whose content changed,
+// removes the output files no longer needed, and writes the cache index.
+// The output files are laid out as Process would write them, from the
+// spool files of the input files.
+func (w *watcher) flush(u *Update) error {
+	var sums []outputSum
+	var out *outputWriter
+	words := 0
+	// next finishes the current output file, if any, and starts the next.
+	next := func(start bool) error {
+		if out != nil {
+			i := len(sums)
+			sum, err := out.close()
+			if err != nil {
+				return err
+			}
+			sums = append(sums, sum)
+			if w.flushed && i < len(w.sums) && w.sums[i] == sum {
+				out.abort()
+			} else {
+				name := outputFileName(w.r.cfg.OutputFile, i)
+				if err := out.commit(name); err != nil {
+					return err
+				}
+				u.Outputs = append(u.Outputs, name)
+			}
+			out = nil
+		}
+		if !start {
+			return nil
+		}
+		var err error
+		out, err = newOutputWriter(filepath.Dir(w.r.cfg.OutputFile))
+		words = 0
+		return err
+	}
+	err := func() error {
+		for _, path := range w.paths {
+			file := w.files[path]
+			if len(file.ops) == 0 {
+				continue
+			}
+			spool, err := os.Open(file.spool)
+			if err != nil {
+				return err
+			}
+			text := bufio.NewReader(spool)
+			for _, op := range file.ops {
+				if out == nil || op.newFile || (words > 0 && words+op.words > w.r.cfg.MaxWordsPerFile) {
+					if err = next(true); err != nil {
+						break
+					}
+				}
+				if op.separate && out.size > 0 {
+					if _, err = io.WriteString(out, "\n"); err != nil {
+						break
+					}
+				}
+				if _, err = io.CopyN(out, text, op.size); err != nil {
+					break
+				}
+				words += op.words
+			}
+			spool.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return next(false)
+	}()
+	if err != nil {
+		if out != nil {
+			out.abort()
+		}
+		return err
+	}
+
+	// The first time, the output files left over from a longer output of
+	// an earlier run are removed too.
+	for i := len(sums); i < len(w.sums) || !w.flushed; i++ {
+		name := outputFileName(w.r.cfg.OutputFile, i)
+		err := os.Remove(name)
+		if os.IsNotExist(err) {
+			if !w.flushed {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		u.Outputs = append(u.Outputs, name)
+	}
+	w.sums = sums
+
+	if w.r.cfg.PIIReport != "" {
+		if err := w.writeReport(); err != nil {
+			return err
+		}
+	}
+	w.flushed = true
+
+	if w.r.cache == nil {
+		return nil
+	}
+	if err := w.r.storeState(); err != nil {
+		return err
+	}
+	return w.r.cache.Flush()
+}
+
+// writeReport rewrites the personal data report when its content changed.
+func (w *watcher) writeReport() error {
+	out, err := newOutputWriter(filepath.Dir(w.r.cfg.PIIReport))
+	if err != nil {
+		return err
+	}
+	for _, path := range w.paths {
+		if line := w.files[path].report; line != "" {
+			if _, err := io.WriteString(out, line+"\n"); err != nil {
+				out.abort()
+				return err
+			}
+		}
+	}
+	sum, err := out.close()
+	if err != nil {
+		out.abort()
+		return err
+	}
+	if w.flushed && sum == w.report {
+		out.abort()
+		return nil
+	}
+	w.report = sum
+	return out.commit(w.r.cfg.PIIReport)
+}
+
+// closeCache closes the cache, if any.
+func (w *watcher) closeCache() error {
+	if w.r.cache == nil {
+		return nil
+	}
+	return w.r.closeCache()
+}
+
+// outputWriter writes an output file to a temporary file, which then
+// replaces the output file at once, hashing its content so that unchanged
+// output files can be left alone.
+type outputWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	hash   hash.Hash
+	size   int64
+}
+
+func newOutputWriter(dir string) (*outputWriter, error) {
+	file, err := ioutil.TempFile(dir, tempPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	return &outputWriter{file: file, writer: bufio.NewWriter(file), hash: sha256.New()}, nil
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	o.hash.Write(p)
+	o.size += int64(len(p))
+	return o.writer.Write(p)
+}
+
+// close finishes the temporary file and returns the hash of its content.
+func (o *outputWriter) close() (outputSum, error) {
+	var sum outputSum
+	err := o.writer.Flush()
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+	copy(sum[:], o.hash.Sum(nil))
+	return sum, err
+}
+
+// commit replaces the file at path with the closed temporary file.
+func (o *outputWriter) commit(path string) error {
+	err := os.Chmod(o.file.Name(), 0644)
+	if err == nil {
+		err = os.Rename(o.file.Name(), path)
+	}
+	if err != nil {
+		o.abort()
+	}
+	return err
+}
+
+// abort removes the temporary file.
+func (o *outputWriter) abort() {
+	o.file.Close()
+	os.Remove(o.file.Name())
+}
@@ -0,0 +1,221 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// piiRules lists the personal data rules in order of precedence.
+var piiRules = []Rule{
+	{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`)},
+	{Name: "iban", Pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`), Valid: isIBAN},
+	{Name: "card", Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Valid: isCardNumber},
+	// IPv6 addresses are matched as whole tokens of word characters, dots
+	// and colons, so that "::" in code and prose is left alone. They come
+	// before IPv4 addresses, which they may end with.
+	{Name: "ip", Pattern: regexp.MustCompile(`(?:^|[^\w:.])([\w.]*:[\w:.]*\w)`), Group: 1, Valid: isIPv6},
+	{Name: "ip", Pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), Valid: isIPv4},
+	{
+		Name:    "phone",
+		Pattern: regexp.MustCompile(`(?:^|[^\w.+-])(\+\d{8,15}|(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?|\d{1,4}[ .-])(?:\d{2,4}[ .-]){1,3}\d{2,4})\b`),
+		Group:   1,
+		Valid:   isPhoneNumber,
+	},
+}
+
+// PIIKinds lists the names of the personal data rules.
+var PIIKinds = []string{"email", "iban", "card", "ip", "phone"}
+
+// PII returns the rules detecting the given kinds of personal data: email
+// addresses, phone numbers, IP addresses, payment card numbers and IBANs.
+// No kinds, or "all", selects every rule.
+func PII(kinds ...string) ([]Rule, error) {
+	selected := map[string]bool{}
+	for _, kind := range kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "all" {
+			return piiRules, nil
+		}
+		found := false
+		for _, rule := range piiRules {
+			found = found || rule.Name == kind
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown personal data kind %q, expected one of %s", kind, strings.Join(PIIKinds, ", "))
+		}
+		selected[kind] = true
+	}
+	if len(selected) == 0 {
+		return piiRules, nil
+	}
+
+	var rules []Rule
+	for _, rule := range piiRules {
+		if selected[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// Pseudonyms replaces matches with numbered tokens such as "[EMAIL_1]", the
+// same match of a rule always yielding the same token. It is safe for
+// concurrent use.
+type Pseudonyms struct {
+	mu     sync.Mutex
+	key    []byte // random key of the hashes of the matches
+	tokens map[string]string
+	counts map[string]int
+}
+
+// NewPseudonyms returns an empty set of pseudonyms with a new random key.
+func NewPseudonyms() *Pseudonyms {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("redact: cannot read random key: " + err.Error())
+	}
+	return &Pseudonyms{key: key, tokens: map[string]string{}, counts: map[string]int{}}
+}
+
+// Replace returns the token of match for the named rule, allocating the next
+// one the first time match is seen. It is a Replacement.
+func (p *Pseudonyms) Replace(rule, match string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Matches are kept as hashes keyed with the random key of p, so that
+	// saved pseudonyms do not list personal data in the clear. The key is
+	// saved with them: whoever reads both can still check guessed values.
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(rule + "\x00" + strings.ToLower(match)))
+	key := hex.EncodeToString(h.Sum(nil))
+	if token, ok := p.tokens[key]; ok {
+		return token
+	}
+	p.counts[rule]++
+	token := "[" + strings.ToUpper(rule) + "_" + strconv.Itoa(p.counts[rule]) + "]"
+	p.tokens[key] = token
+	return token
+}
+
+// pseudonymState is the JSON form of Pseudonyms.
+type pseudonymState struct {
+	Key    []byte            `json:"key"`
+	Tokens map[string]string `json:"tokens"`
+	Counts map[string]int    `json:"counts"`
+}
+
+// MarshalJSON saves the tokens allocated so far, with the matches hashed,
+// and the key of the hashes. Like the matches themselves, the result is
+// sensitive data.
+func (p *Pseudonyms) MarshalJSON() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return json.Marshal(pseudonymState{Key: p.key, Tokens: p.tokens, Counts: p.counts})
+}
+
+// UnmarshalJSON restores tokens saved by MarshalJSON, so that the same
+// matches keep their tokens across runs.
+func (p *Pseudonyms) UnmarshalJSON(data []byte) error {
+	var state pseudonymState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens, p.counts = state.Tokens, state.Counts
+	if len(state.Key) > 0 {
+		p.key = state.Key
+	}
+	if p.tokens == nil {
+		p.tokens = map[string]string{}
+	}
+	if p.counts == nil {
+		p.counts = map[string]int{}
+	}
+	return nil
+}
+
+// digits returns the decimal digits of s.
+func digits(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// isCardNumber reports whether s holds 13 to 19 digits passing the Luhn
+// check.
+func isCardNumber(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(d); i++ {
+		n := int(d[len(d)-1-i] - '0')
+		if i%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// isIBAN reports whether s is an IBAN with a valid ISO 13616 check sum.
+func isIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	var sb strings.Builder
+	for _, c := range s[4:] + s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			sb.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			sb.WriteString(strconv.Itoa(int(c-'A') + 10))
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(sb.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// isIPv4 reports whether s is an IPv4 address.
+func isIPv4(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+// isIPv6 reports whether s is an IPv6 address with at least one digit,
+// which tells addresses such as "fe80::1" from names such as "Foo::add".
+func isIPv6(s string) bool {
+	return strings.Contains(s, ":") && strings.ContainsAny(s, "0123456789") && net.ParseIP(s) != nil
+}
+
+// datePattern matches dates that look like phone numbers.
+var datePattern = regexp.MustCompile(`^\d{4}[-./]\d{1,2}[-./]\d{1,2}$|^\d{1,2}[-./]\d{1,2}[-./]\d{2,4}$`)
+
+// isPhoneNumber reports whether s has as many digits as a phone number and
+// is not a date.
+func isPhoneNumber(s string) bool {
+	n := len(digits(s))
+	return n >= 8 && n <= 15 && !datePattern.MatchString(s)
+}
@@ -0,0 +1,134 @@
+package redact
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule detects one kind of sensitive data.
+type Rule struct {
+	Name    string                  // identifies the rule in placeholders and reports
+	Pattern *regexp.Regexp          // matches the data, or the data with its context
+	Group   int                     // submatch holding the data, 0 for the whole match
+	Valid   func(match string) bool // rejects false positives, nil to accept all matches
+}
+
+// Finding is a match of a rule in a text.
+type Finding struct {
+	Rule       string // name of the rule
+	Line       int    // line of the match, from 1
+	Start, End int    // byte offsets of the match in the text
+}
+
+// Replacement returns the text substituted for a match of the named rule.
+type Replacement func(rule, match string) string
+
+// Redactor finds and replaces sensitive data in text.
+type Redactor struct {
+	rules   []Rule
+	replace Replacement
+}
+
+// New returns a redactor applying the given rules and replacing matches
+// with their Placeholder.
+func New(rules ...Rule) *Redactor {
+	return &Redactor{rules: rules, replace: func(rule, _ string) string { return Placeholder(rule) }}
+}
+
+// WithReplacement sets the function computing the text substituted for
+// matches, and returns r.
+func (r *Redactor) WithReplacement(fn Replacement) *Redactor {
+	r.replace = fn
+	return r
+}
+
+// Placeholder returns the text replacing data matched by the named rule.
+func Placeholder(rule string) string {
+	return "[REDACTED:" + rule + "]"
+}
+
+// Find returns the matches of the rules in text, ordered by position. When
+// matches overlap, the match of the rule listed first is kept, so specific
+// rules should come before generic ones; among the matches of one rule, the
+// one starting first, or else the longest, is kept.
+func (r *Redactor) Find(text string) []Finding {
+	var kept []Finding
+	for _, rule := range r.rules {
+		var findings []Finding
+		for _, m := range rule.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*rule.Group], m[2*rule.Group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if rule.Valid != nil && !rule.Valid(text[start:end]) {
+				continue
+			}
+			findings = append(findings, Finding{Rule: rule.Name, Start: start, End: end})
+		}
+		sort.SliceStable(findings, func(i, j int) bool {
+			if findings[i].Start != findings[j].Start {
+				return findings[i].Start < findings[j].Start
+			}
+			return findings[i].End > findings[j].End
+		})
+		for _, f := range findings {
+			// kept is ordered by position: f overlaps a kept match if it
+			// ends after the start of the first match ending after f starts.
+			i := sort.Search(len(kept), func(i int) bool { return kept[i].End > f.Start })
+			if i < len(kept) && kept[i].Start < f.End {
+				continue
+			}
+			kept = append(kept, Finding{})
+			copy(kept[i+1:], kept[i:])
+			kept[i] = f
+		}
+	}
+
+	line, pos := 1, 0
+	for i := range kept {
+		line += strings.Count(text[pos:kept[i].Start], "\n")
+		pos = kept[i].Start
+		kept[i].Line = line
+	}
+	return kept
+}
+
+// Redact returns text with every match replaced as configured, along with
+// the matches.
+func (r *Redactor) Redact(text string) (string, []Finding) {
+	findings := r.Find(text)
+	if len(findings) == 0 {
+		return text, nil
+	}
+
+	var sb strings.Builder
+	pos := 0
+	for _, f := range findings {
+		sb.WriteString(text[pos:f.Start])
+		sb.WriteString(r.replace(f.Rule, text[f.Start:f.End]))
+		pos = f.End
+	}
+	sb.WriteString(text[pos:])
+	return sb.String(), findings
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var h float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,56 @@
+package redact
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+const (
+	// minSecretEntropy is the entropy, in bits per character, above which a
+	// value of 8 characters assigned to a password or token identifier is
+	// taken for a secret.
+	minSecretEntropy = 3.0
+	// maxSecretEntropy caps the entropy required of longer values, which
+	// grows with length as ordinary text gains entropy too.
+	maxSecretEntropy = 3.3
+)
+
+// reference matches values naming a secret rather than holding one:
+// identifiers such as maxTokens, dotted paths such as cfg.Password, and
+// numbers.
+var reference = regexp.MustCompile(`^(?:[A-Za-z_]+[0-9]*|[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)+|[0-9][0-9_.]*)$`)
+
+// Secrets returns the rules detecting credentials: cloud provider and
+// service keys, JWTs, PEM private keys, and high-entropy values assigned to
+// password, secret, token or key identifiers.
+func Secrets() []Rule {
+	return []Rule{
+		{Name: "private-key", Pattern: regexp.MustCompile(`(?s)-----BEGIN[A-Z0-9 ]* PRIVATE KEY(?: BLOCK)?-----.*?-----END[A-Z0-9 ]* PRIVATE KEY(?: BLOCK)?-----`)},
+		{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+		{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws[\w.-]{0,20}(?:secret|key)[\w.-]{0,20}["']?\s*(?::=|=>|=|:)\s*["']?([A-Za-z0-9/+=]{40})\b`), Group: 1},
+		{Name: "gcp-api-key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+		{Name: "azure-storage-key", Pattern: regexp.MustCompile(`AccountKey=([A-Za-z0-9+/]{86}==)`), Group: 1},
+		{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+		{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+		{Name: "stripe-key", Pattern: regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}\b`)},
+		{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+		{
+			Name:    "secret",
+			Pattern: regexp.MustCompile(`(?i)[\w.-]*(?:passw(?:or)?d|pwd|secret|token|api_?key|access_?key|auth_?key|credentials?)[\w.-]*["']?\s*(?::=|=>|=|:)\s*["']?([^\s"'` + "`" + `,;]{8,})`),
+			Group:   1,
+			Valid:   isSecretValue,
+		},
+	}
+}
+
+// isSecretValue reports whether a value assigned to a secret identifier
+// looks like a literal secret rather than a reference to one.
+func isSecretValue(s string) bool {
+	if strings.ContainsAny(s, "(){}<>") || strings.HasPrefix(s, "$") || strings.HasPrefix(s, "[REDACTED:") ||
+		reference.MatchString(s) {
+		return false
+	}
+	required := minSecretEntropy + 0.25*math.Log2(float64(len(s))/8)
+	return entropy(s) >= math.Min(required, maxSecretEntropy)
+}